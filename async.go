@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+)
+
+// BreakfastResult is a breakfast being served in the background.
+type BreakfastResult struct {
+	done chan struct{}
+	err  error
+}
+
+// AsyncServe starts serving breakfast in its own goroutine and returns
+// straight away. The breakfast's spans are all started inside that goroutine.
+func AsyncServe(ctx context.Context, opts ...Option) *BreakfastResult {
+	res := &BreakfastResult{done: make(chan struct{})}
+	go func() {
+		defer close(res.done)
+		res.err = ServeBreakfast(ctx, opts...)
+	}()
+	return res
+}
+
+// Done is closed once the breakfast has been served or ruined.
+func (r *BreakfastResult) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait blocks until the breakfast is finished and returns its error.
+func (r *BreakfastResult) Wait() error {
+	<-r.done
+	return r.err
+}
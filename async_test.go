@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+func TestAsyncServeThreeTables(t *testing.T) {
+	mt := useMockTracer(t)
+
+	var tables []*BreakfastResult
+	for table := 1; table <= 3; table++ {
+		tables = append(tables, AsyncServe(context.Background(), WithDryRun(), WithTableNumber(table)))
+	}
+	for i, res := range tables {
+		if err := res.Wait(); err != nil {
+			t.Errorf("table %d: %v", i+1, err)
+		}
+		select {
+		case <-res.Done():
+		default:
+			t.Errorf("table %d: Done isn't closed after Wait", i+1)
+		}
+	}
+	breakfasttest.AssertSpanCount(t, mt, "ServeHotCakes", 3)
+}
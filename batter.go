@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"math"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+var (
+	ErrNotEnoughFlour = errors.New("not enough flour")
+	ErrNotEnoughEggs  = errors.New("not enough eggs")
+	ErrNotEnoughMilk  = errors.New("not enough milk")
+	ErrNotEnoughSugar = errors.New("not enough sugar")
+
+	ErrInvalidCount  = errors.New("pancake count must be positive")
+	ErrBatchTooLarge = errors.New("too many pancakes for one batch")
+)
+
+// The most pancakes made in one go
+const MaxBatchSize = 100
+
+// Batter holds the ingredients pancakes are made from.
+// Flour and Milk are measured in cups, Sugar in tablespoons.
+type Batter struct {
+	Flour float64
+	Eggs  float64
+	Milk  float64
+	Sugar float64
+}
+
+// How much of each ingredient goes into a single pancake
+var pancakeRecipe = Batter{
+	Flour: 0.25,
+	Eggs:  0.5,
+	Milk:  0.25,
+	Sugar: 0.5,
+}
+
+// Nutrition in a cup of flour, an egg, a cup of milk and a tablespoon of
+// sugar, the units Batter is measured in
+var (
+	flourNutrition = Nutrition{Carbs: 95, Protein: 13, Fat: 1.2}
+	eggNutrition   = Nutrition{Carbs: 0.4, Protein: 6.3, Fat: 4.8}
+	milkNutrition  = Nutrition{Carbs: 12, Protein: 8, Fat: 8}
+	sugarNutrition = Nutrition{Carbs: 12.5}
+)
+
+// Nutrition is what is in the batter.
+func (b Batter) Nutrition() Nutrition {
+	return flourNutrition.Times(b.Flour).
+		Add(eggNutrition.Times(b.Eggs)).
+		Add(milkNutrition.Times(b.Milk)).
+		Add(sugarNutrition.Times(b.Sugar))
+}
+
+// BatterFor returns exactly enough batter to make n pancakes.
+func BatterFor(n int) Batter {
+	return Batter{
+		Flour: pancakeRecipe.Flour * float64(n),
+		Eggs:  pancakeRecipe.Eggs * float64(n),
+		Milk:  pancakeRecipe.Milk * float64(n),
+		Sugar: pancakeRecipe.Sugar * float64(n),
+	}
+}
+
+// Yield returns how many pancakes the batter can make. If it can't make even
+// one, the error names the ingredient that ran short.
+func (b Batter) Yield() (int, error) {
+	ingredients := []struct {
+		have, need float64
+		short      error
+	}{
+		{b.Flour, pancakeRecipe.Flour, ErrNotEnoughFlour},
+		{b.Eggs, pancakeRecipe.Eggs, ErrNotEnoughEggs},
+		{b.Milk, pancakeRecipe.Milk, ErrNotEnoughMilk},
+		{b.Sugar, pancakeRecipe.Sugar, ErrNotEnoughSugar},
+	}
+
+	// Compare as floats so a vast amount of one ingredient can't overflow
+	yield, limit := float64(math.MaxInt32), error(nil)
+	for _, i := range ingredients {
+		if n := math.Floor(i.have / i.need); n < yield {
+			yield, limit = n, i.short
+		}
+	}
+	if yield < 1 {
+		return 0, limit
+	}
+	return int(yield), nil
+}
+
+// MakePancakesFromBatter makes as many pancakes as the batter yields. Like
+// MakePancakesE it won't make more than MaxBatchSize, failing with
+// ErrBatchTooLarge rather than using only some of the batter.
+func MakePancakesFromBatter(b Batter) ([]breakfast.Pancake, error) {
+	n, err := b.Yield()
+	if err != nil {
+		return nil, err
+	}
+	return MakePancakesE(n)
+}
+
+// MakePancakesE makes n pancakes, or fails if n is not positive or is larger
+// than MaxBatchSize.
+func MakePancakesE(n int) ([]breakfast.Pancake, error) {
+	if err := checkCount(n); err != nil {
+		return nil, err
+	}
+	return breakfast.MakePancakes(n), nil
+}
+
+func checkCount(n int) error {
+	if n <= 0 {
+		return ErrInvalidCount
+	}
+	if n > MaxBatchSize {
+		return ErrBatchTooLarge
+	}
+	return nil
+}
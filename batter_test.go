@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBatterYield(t *testing.T) {
+	surplus := BatterFor(4)
+	surplus.Milk *= 3
+	short := BatterFor(4)
+	short.Eggs = 0
+
+	for _, tc := range []struct {
+		name   string
+		batter Batter
+		want   int
+		err    error
+	}{
+		{"exact", BatterFor(4), 4, nil},
+		{"surplus", surplus, 4, nil},
+		{"shortfall", short, 0, ErrNotEnoughEggs},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.batter.Yield()
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got error %v, want %v", err, tc.err)
+			}
+			if got != tc.want {
+				t.Errorf("yields %d pancakes, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMakePancakesFromBatter(t *testing.T) {
+	cakes, err := MakePancakesFromBatter(BatterFor(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cakes) != 4 {
+		t.Errorf("made %d pancakes, want 4", len(cakes))
+	}
+
+	if _, err := MakePancakesFromBatter(Batter{Eggs: 1, Milk: 1, Sugar: 1}); !errors.Is(err, ErrNotEnoughFlour) {
+		t.Errorf("without flour got %v, want %v", err, ErrNotEnoughFlour)
+	}
+	if _, err := MakePancakesFromBatter(BatterFor(MaxBatchSize + 1)); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("with batter for %d got %v, want %v", MaxBatchSize+1, err, ErrBatchTooLarge)
+	}
+}
+
+func TestMakePancakesE(t *testing.T) {
+	for _, tc := range []struct {
+		n   int
+		err error
+	}{
+		{0, ErrInvalidCount},
+		{-1, ErrInvalidCount},
+		{1 << 30, ErrBatchTooLarge},
+		{MaxBatchSize + 1, ErrBatchTooLarge},
+		{MaxBatchSize, nil},
+		{3, nil},
+	} {
+		cakes, err := MakePancakesE(tc.n)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("MakePancakesE(%d) got error %v, want %v", tc.n, err, tc.err)
+			continue
+		}
+		if err == nil && len(cakes) != tc.n {
+			t.Errorf("MakePancakesE(%d) made %d pancakes", tc.n, len(cakes))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+// BreakerState is whether a BreakerTransport is passing spans on.
+type BreakerState int
+
+const (
+	// BreakerClosed passes spans on to the backend
+	BreakerClosed BreakerState = iota
+	// BreakerOpen drops spans until the cooldown is over
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	if s == BreakerOpen {
+		return "open"
+	}
+	return "closed"
+}
+
+// BreakerTransport protects the kitchen from an unhealthy tracing backend.
+// After threshold failures in a row it trips open and drops spans, rather
+// than trying and failing on every one, until cooldown has passed. The first
+// send after that is a trial: if it works the breaker closes again, and if
+// not it stays open for another cooldown.
+type BreakerTransport struct {
+	transport jaeger.Transport
+	threshold int
+	cooldown  time.Duration
+	clock     Clock
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func NewBreakerTransport(t jaeger.Transport, threshold int, cooldown time.Duration) *BreakerTransport {
+	return &BreakerTransport{
+		transport: t,
+		threshold: threshold,
+		cooldown:  cooldown,
+		clock:     RealClock{},
+	}
+}
+
+// State reports whether the breaker is currently dropping spans.
+func (b *BreakerTransport) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures >= b.threshold && b.clock.Now().Before(b.openUntil) {
+		return BreakerOpen
+	}
+	return BreakerClosed
+}
+
+func (b *BreakerTransport) Append(span *jaeger.Span) (int, error) {
+	if b.State() == BreakerOpen {
+		return 0, nil
+	}
+	n, err := b.transport.Append(span)
+	b.record(err)
+	return n, err
+}
+
+func (b *BreakerTransport) Flush() (int, error) {
+	if b.State() == BreakerOpen {
+		return 0, nil
+	}
+	n, err := b.transport.Flush()
+	b.record(err)
+	return n, err
+}
+
+func (b *BreakerTransport) Close() error {
+	return b.transport.Close()
+}
+
+func (b *BreakerTransport) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if b.failures >= b.threshold {
+			log.Info("Tracing backend is back, reporting spans again")
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		// Warn once when tripping, not on every retry after a cooldown
+		if b.failures == b.threshold {
+			log.Warningf("Tracing backend failed %d times in a row, dropping spans for %s: %s", b.failures, b.cooldown, err)
+		}
+		b.openUntil = b.clock.Now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+// fakeTransport fails while down is set, counting what reaches it.
+type fakeTransport struct {
+	down  bool
+	sends int
+}
+
+func (f *fakeTransport) send() (int, error) {
+	f.sends++
+	if f.down {
+		return 0, errors.New("backend is down")
+	}
+	return 1, nil
+}
+
+func (f *fakeTransport) Append(*jaeger.Span) (int, error) { return f.send() }
+func (f *fakeTransport) Flush() (int, error)              { return f.send() }
+func (f *fakeTransport) Close() error                     { return nil }
+
+func TestBreakerTripsAndRecovers(t *testing.T) {
+	backend := &fakeTransport{down: true}
+	clock := NewFakeClock(time.Unix(0, 0))
+	b := NewBreakerTransport(backend, 3, time.Minute)
+	b.clock = clock
+
+	for i := 0; i < 3; i++ {
+		if b.State() != BreakerClosed {
+			t.Fatalf("open after %d failures, want closed until 3", i)
+		}
+		b.Flush()
+	}
+	if b.State() != BreakerOpen {
+		t.Fatal("closed after 3 failures, want open")
+	}
+	// Open, the backend isn't bothered
+	b.Flush()
+	if backend.sends != 3 {
+		t.Errorf("backend got %d sends, want none once open", backend.sends-3)
+	}
+
+	backend.down = false
+	clock.Advance(time.Minute)
+	if b.State() != BreakerClosed {
+		t.Fatal("still open after the cooldown")
+	}
+	if _, err := b.Flush(); err != nil {
+		t.Fatalf("trial send: %v", err)
+	}
+	if b.State() != BreakerClosed || backend.sends != 4 {
+		t.Errorf("after a good trial send the breaker is %s with %d sends, want closed with 4", b.State(), backend.sends)
+	}
+}
+
+func TestBreakerStaysOpenAfterFailedTrial(t *testing.T) {
+	backend := &fakeTransport{down: true}
+	clock := NewFakeClock(time.Unix(0, 0))
+	b := NewBreakerTransport(backend, 1, time.Minute)
+	b.clock = clock
+
+	b.Flush()
+	clock.Advance(time.Minute)
+	b.Flush()
+	if b.State() != BreakerOpen {
+		t.Error("closed after the trial send failed, want open for another cooldown")
+	}
+}
@@ -0,0 +1,51 @@
+// Package breakfasttest helps tests check the spans a breakfast produced,
+// recorded with opentracing's mocktracer.
+package breakfasttest
+
+import (
+	"testing"
+
+	mocktracer "github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// FindSpans returns every finished span called name, in the order they
+// finished.
+func FindSpans(mt *mocktracer.MockTracer, name string) []*mocktracer.MockSpan {
+	var spans []*mocktracer.MockSpan
+	for _, span := range mt.FinishedSpans() {
+		if span.OperationName == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// AssertSpanExists fails the test unless a span called name has finished, and
+// returns the first one that did.
+func AssertSpanExists(t testing.TB, mt *mocktracer.MockTracer, name string) *mocktracer.MockSpan {
+	t.Helper()
+	spans := FindSpans(mt, name)
+	if len(spans) == 0 {
+		t.Errorf("no finished span called %q", name)
+		return nil
+	}
+	return spans[0]
+}
+
+// AssertSpanCount fails the test unless exactly n spans called name finished.
+func AssertSpanCount(t testing.TB, mt *mocktracer.MockTracer, name string, n int) {
+	t.Helper()
+	if got := len(FindSpans(mt, name)); got != n {
+		t.Errorf("got %d finished spans called %q, want %d", got, name, n)
+	}
+}
+
+// SpanTag returns the value of tag key on the first finished span called name,
+// or nil if there is no such span or it has no such tag.
+func SpanTag(mt *mocktracer.MockTracer, name, key string) interface{} {
+	spans := FindSpans(mt, name)
+	if len(spans) == 0 {
+		return nil
+	}
+	return spans[0].Tag(key)
+}
@@ -0,0 +1,95 @@
+package breakfasttest
+
+import (
+	"fmt"
+	"testing"
+
+	mocktracer "github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// recorder notes the failures a helper reports instead of failing the test.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+// knownSpans finishes two FlipPancake spans, tagged with their index, and a
+// ServeHotCakes span.
+func knownSpans() *mocktracer.MockTracer {
+	mt := mocktracer.New()
+	for i := 0; i < 2; i++ {
+		span := mt.StartSpan("FlipPancake")
+		span.SetTag("pancake.index", i)
+		span.Finish()
+	}
+	mt.StartSpan("ServeHotCakes").Finish()
+	return mt
+}
+
+func TestFindSpans(t *testing.T) {
+	mt := knownSpans()
+	spans := FindSpans(mt, "FlipPancake")
+	if len(spans) != 2 {
+		t.Fatalf("found %d FlipPancake spans, want 2", len(spans))
+	}
+	for i, span := range spans {
+		if got := span.Tag("pancake.index"); got != i {
+			t.Errorf("span %d has pancake.index %v, want them in the order they finished", i, got)
+		}
+	}
+	if spans := FindSpans(mt, "EatPancakes"); spans != nil {
+		t.Errorf("found %d EatPancakes spans, want none", len(spans))
+	}
+}
+
+func TestAssertSpanExists(t *testing.T) {
+	mt := knownSpans()
+	r := &recorder{TB: t}
+	if span := AssertSpanExists(r, mt, "ServeHotCakes"); span == nil || span.OperationName != "ServeHotCakes" {
+		t.Errorf("got %v, want the ServeHotCakes span", span)
+	}
+	if len(r.failures) != 0 {
+		t.Errorf("failed with %q for a span that exists", r.failures)
+	}
+
+	if span := AssertSpanExists(r, mt, "EatPancakes"); span != nil {
+		t.Errorf("got %v for a missing span, want nil", span)
+	}
+	if len(r.failures) != 1 {
+		t.Errorf("got %d failures for a missing span, want 1", len(r.failures))
+	}
+}
+
+func TestAssertSpanCount(t *testing.T) {
+	mt := knownSpans()
+	r := &recorder{TB: t}
+	AssertSpanCount(r, mt, "FlipPancake", 2)
+	AssertSpanCount(r, mt, "EatPancakes", 0)
+	if len(r.failures) != 0 {
+		t.Errorf("failed with %q for the right counts", r.failures)
+	}
+
+	AssertSpanCount(r, mt, "FlipPancake", 3)
+	if len(r.failures) != 1 {
+		t.Errorf("got %d failures for the wrong count, want 1", len(r.failures))
+	}
+}
+
+func TestSpanTag(t *testing.T) {
+	mt := knownSpans()
+	if got := SpanTag(mt, "FlipPancake", "pancake.index"); got != 0 {
+		t.Errorf("got pancake.index %v, want the first span's 0", got)
+	}
+	if got := SpanTag(mt, "FlipPancake", "pancake.state"); got != nil {
+		t.Errorf("got %v for a missing tag, want nil", got)
+	}
+	if got := SpanTag(mt, "EatPancakes", "pancake.index"); got != nil {
+		t.Errorf("got %v for a missing span, want nil", got)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+// waitForSleepers waits until n things are waiting on the clock.
+func waitForSleepers(t *testing.T, c *FakeClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		waiting := len(c.waiters)
+		c.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("nothing started waiting on the clock")
+}
+
+func TestFakeClockAdvance(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	after := c.After(time.Minute)
+
+	c.Advance(59 * time.Second)
+	select {
+	case <-after:
+		t.Fatal("fired before a minute had passed")
+	default:
+	}
+	c.Advance(time.Second)
+	select {
+	case now := <-after:
+		if !now.Equal(time.Unix(60, 0)) {
+			t.Errorf("fired at %s, want a minute in", now)
+		}
+	default:
+		t.Fatal("didn't fire once a minute had passed")
+	}
+}
+
+func TestFakeClockDrivesCooking(t *testing.T) {
+	mt := useMockTracer(t)
+	clock := NewFakeClock(time.Unix(0, 0))
+	// Anything left on for more than half a minute burns
+	burner := BurnDetectorFunc(func(_ Cookable, cooked time.Duration) bool { return cooked > 30*time.Second })
+
+	cooked := make(chan error, 1)
+	go func() {
+		cooked <- FlipPancakes(context.Background(), breakfast.MakePancakes(2), cleanFlips(), WithBurnDetector(burner), WithCookDuration(time.Minute), WithClock(clock))
+	}()
+	waitForSleepers(t, clock, 1)
+	select {
+	case err := <-cooked:
+		t.Fatalf("finished cooking before the clock moved: %v", err)
+	default:
+	}
+
+	clock.Advance(time.Minute)
+	select {
+	case err := <-cooked:
+		if !errors.Is(err, ErrBurntPancake) {
+			t.Errorf("got %v, want the pancakes burnt after a minute", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("still cooking after the clock moved on")
+	}
+	if ms := breakfasttest.SpanTag(mt, "FlipPancake", "cook_time_ms"); ms != int64(60000) {
+		t.Errorf("FlipPancake cook_time_ms = %v, want 60000", ms)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+var ErrNoCoffee = errors.New("no cups of coffee to brew")
+
+// How long a single cup takes to brew
+const brewTime = 250 * time.Millisecond
+
+// Coffee is one brewed cup.
+type Coffee struct {
+	Cup int
+}
+
+// BrewCoffee brews the cups one after the other in the background, sending
+// each on the returned channel as it is ready. It is meant to run alongside
+// the pancakes, so it has its own span that overlaps theirs in the trace.
+// Brewing stops if ctx is cancelled.
+func BrewCoffee(ctx context.Context, cups int, opts ...Option) (<-chan Coffee, error) {
+	o := newOptions(opts)
+	if cups <= 0 {
+		return nil, ErrNoCoffee
+	}
+
+	span, ctx := startSpan(ctx, "BrewCoffee")
+	span.SetTag("coffee.cups", cups)
+	out := make(chan Coffee)
+	go func() {
+		brewed := 0
+		defer func() {
+			close(out)
+			span.SetTag("coffee.brewed", brewed)
+			span.Finish()
+		}()
+
+		for cup := 0; cup < cups; cup++ {
+			select {
+			case <-o.clock.After(o.brewTime()):
+			case <-ctx.Done():
+				span.LogFields(otlog.String("event", "cancelled"))
+				return
+			}
+			select {
+			case out <- Coffee{Cup: cup}:
+				brewed++
+			case <-ctx.Done():
+				span.LogFields(otlog.String("event", "cancelled"))
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+func TestBrewCoffee(t *testing.T) {
+	useMockTracer(t)
+
+	coffee, err := BrewCoffee(context.Background(), 3, WithDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cups := 0
+	for range coffee {
+		cups++
+	}
+	if cups != 3 {
+		t.Errorf("brewed %d cups, want 3", cups)
+	}
+
+	if _, err := BrewCoffee(context.Background(), 0); !errors.Is(err, ErrNoCoffee) {
+		t.Errorf("brewing no cups got %v, want %v", err, ErrNoCoffee)
+	}
+}
+
+func TestCoffeeBrewsAlongsideThePancakes(t *testing.T) {
+	mt := useMockTracer(t)
+
+	if err := ServeBreakfast(context.Background(), WithDryRun(), WithCoffee(2)); err != nil {
+		t.Fatal(err)
+	}
+	// The last cup is poured before the brew's span is finished
+	waitForEvent(t, mt, "BrewCoffee")
+	brew := breakfasttest.AssertSpanExists(t, mt, "BrewCoffee")
+	pancakes := breakfasttest.AssertSpanExists(t, mt, "Pancakes")
+	if brew.ParentID != pancakes.ParentID {
+		t.Errorf("BrewCoffee's parent is span %d, want the Pancakes' parent %d", brew.ParentID, pancakes.ParentID)
+	}
+	if got := breakfasttest.SpanTag(mt, "ServeHotCakes", "coffee.cups"); got != 2 {
+		t.Errorf("ServeHotCakes coffee.cups = %v, want 2", got)
+	}
+}
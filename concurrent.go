@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	logging "github.com/ipfs/go-log"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+// FlipPancakesConcurrent cooks the pancakes on a pool of workers, each one
+// flipping, cooking and checking a pancake at a time. The first error stops
+// the remaining workers and is returned, unless WithContinueOnError has them
+// cook every pancake and return a *BatchError of the failures in pancake
+// order.
+func FlipPancakesConcurrent(ctx context.Context, cakes []breakfast.Pancake, workers int, opts ...Option) (err error) {
+	o := contextOptions(ctx, opts)
+
+	eip := log.EventBegin(ctx, "FlipPancakesConcurrent", eventFields(ctx, logging.LoggableMap{"workers": workers}))
+	defer func() {
+		if err != nil {
+			eip.SetError(err)
+		}
+		eip.Done()
+	}()
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Cancelled as soon as any worker fails so the rest stop promptly
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	// Each worker reports at most one error before it quits
+	errs := make(chan error, workers)
+	// What went wrong with each pancake, when carrying on regardless
+	failed := make([]error, len(cakes))
+	var early atomic.Bool
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				pulled, err := cookPancake(ctx, &cakes[p], p, o)
+				if pulled {
+					early.Store(true)
+				}
+				var burnt *BurntPancakeError
+				switch {
+				case err == nil:
+				case o.continueOnError && errors.As(err, &burnt):
+					failed[p] = burnt
+				case o.continueOnError && ctx.Err() == nil:
+					failed[p] = fmt.Errorf("pancake %d: %w", p, err)
+				default:
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for p := range cakes {
+		select {
+		case jobs <- p:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	if early.Load() {
+		eip.Append(logging.LoggableMap{"pulled_early": true})
+	}
+
+	// Errors are sent before cancelling, so the first one is the real cause
+	if err, ok := <-errs; ok {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var batch []error
+	for _, err := range failed {
+		if err != nil {
+			batch = append(batch, err)
+		}
+	}
+	if len(batch) > 0 {
+		return fmt.Errorf("flipping pancakes: %w", &BatchError{errs: batch})
+	}
+	return nil
+}
+
+// cookPancake flips a single pancake, lets it cook and checks it didn't burn,
+// all under its own span, just as flipAll does for each item in a batch. It
+// reports whether the pancake was pulled early for burning.
+func cookPancake(ctx context.Context, cake *breakfast.Pancake, index int, o *options) (early bool, err error) {
+	c, err := flipItem(ctx, o, pancakeFlips, index, cake)
+	defer c.span.Finish()
+	if err != nil {
+		return false, err
+	}
+	if early, err = o.cook(ctx, o.cookTimeOf(cake), []*cookItem{c}); err != nil {
+		return false, err
+	}
+	if err := c.done(o); err != nil {
+		return early, fmt.Errorf("flipping pancakes: %w", err)
+	}
+	return early, nil
+}
+
+// SyrupPancakesConcurrent syrups the pancakes like SyrupPancakes, but on a
+// pool of workers. Pancakes are sent on the returned channel as they are
+// ready, so not necessarily in order. Sending stops when SyrupPancakes' would.
+func SyrupPancakesConcurrent(ctx context.Context, cakes []breakfast.Pancake, workers int, opts ...Option) <-chan breakfast.Pancake {
+	o := contextOptions(ctx, opts)
+	if workers < 1 {
+		workers = 1
+	}
+
+	eip := log.EventBegin(ctx, "SyrupPancakesConcurrent", eventFields(ctx, logging.LoggableMap{"syrup": o.syrup, "workers": workers}))
+	out := make(chan breakfast.Pancake, o.buffer)
+	go func() {
+		// Cancelled when the batch is given up on, to stop the workers
+		wctx, giveUp := context.WithCancel(ctx)
+		defer giveUp()
+
+		// Shared by the workers
+		var (
+			mu       sync.Mutex
+			mistakes []breakfast.Pancake
+			failOnce sync.Once
+		)
+		// Give up on the batch, failing the event with err and stopping the
+		// other workers
+		fail := func(err error) {
+			failOnce.Do(func() { eip.SetError(err) })
+			giveUp()
+		}
+		sender := &pancakeSender{ctx: ctx, o: o, out: out, stop: wctx.Done(), fail: fail}
+		defer func() {
+			if o.mistakes != nil {
+				*o.mistakes = mistakes
+			}
+			o.leftSoggy(len(mistakes))
+			close(out)
+			sender.done(eip)
+			eip.Done()
+		}()
+
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for p := range jobs {
+					if err := syrupPancake(wctx, &cakes[p], p, o); err != nil {
+						log.Warning(fmt.Errorf("pancake %d: %w", p, ErrSoggyPancake))
+						o.soggy()
+						mu.Lock()
+						mistakes = append(mistakes, cakes[p])
+						n := len(mistakes)
+						mu.Unlock()
+						if o.maxMistakes > 0 && n > o.maxMistakes {
+							// This batch is a lost cause
+							fail(fmt.Errorf("%w: %d soggy pancakes", ErrTooManyMistakes, n))
+						}
+						continue
+					}
+					if !sender.send(cakes[p]) {
+						return
+					}
+				}
+			}()
+		}
+
+	feed:
+		for p := range cakes {
+			select {
+			case jobs <- p:
+			case <-wctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+
+		// Cancelled with nothing in hand, e.g. between pancakes
+		if ctx.Err() != nil {
+			sender.cancel(0)
+			return
+		}
+		if wctx.Err() != nil {
+			return
+		}
+		if len(mistakes) > 0 && o.syrupRetries > 0 {
+			var fixed []breakfast.Pancake
+			fixed, mistakes = fixSoggyPancakes(ctx, mistakes, o.syrupRetries, o)
+			for _, cake := range fixed {
+				if !sender.send(cake) {
+					return
+				}
+			}
+		}
+		if len(mistakes) > 0 {
+			eip.SetError(fmt.Errorf("%d pancakes: %w", len(mistakes), ErrSoggyPancake))
+		}
+	}()
+
+	return out
+}
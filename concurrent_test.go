@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	prometheus "github.com/prometheus/client_golang/prometheus"
+	testutil "github.com/prometheus/client_golang/prometheus/testutil"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+func TestFlipPancakesConcurrentCooksEveryPancake(t *testing.T) {
+	useMockTracer(t)
+	tl := &tally{}
+
+	err := FlipPancakesConcurrent(context.Background(), breakfast.MakePancakes(20), 4, WithDryRun(), withTally(tl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cooked := tl.cooked.Load(); cooked != 20 {
+		t.Errorf("cooked %d pancakes, want 20", cooked)
+	}
+}
+
+func TestFlipPancakesConcurrentStopsOnBurn(t *testing.T) {
+	useMockTracer(t)
+	cakes := breakfast.MakePancakes(20)
+	burner := BurnDetectorFunc(func(item Cookable, _ time.Duration) bool {
+		return item == Cookable(&cakes[7])
+	})
+
+	err := FlipPancakesConcurrent(context.Background(), cakes, 4, cleanFlips(), WithCookDuration(time.Millisecond), WithBurnDetector(burner))
+	var burnt *BurntPancakeError
+	if !errors.As(err, &burnt) || burnt.Index != 7 {
+		t.Fatalf("got %v, want pancake 7 burnt", err)
+	}
+}
+
+// flipBreakfasts flips a batch of pancakes for each breakfast at once, each
+// on a pool of workers, all counting in the same Metrics. burn picks the
+// pancake that burns in a batch, if any.
+func flipBreakfasts(t *testing.T, breakfasts, pancakes int, burn func(cakes []breakfast.Pancake) Option) *Metrics {
+	t.Helper()
+	m := NewMetrics()
+	if err := m.Register(prometheus.NewRegistry()); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for b := 0; b < breakfasts; b++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cakes := breakfast.MakePancakes(pancakes)
+			opts := []Option{WithMetrics(m), WithDryRun()}
+			if burn != nil {
+				opts = []Option{WithMetrics(m), cleanFlips(), WithCookDuration(time.Millisecond), burn(cakes)}
+			}
+			FlipPancakesConcurrent(context.Background(), cakes, 16, opts...)
+		}()
+	}
+	wg.Wait()
+	return m
+}
+
+func TestFlipPancakesConcurrentCountsCookedExactly(t *testing.T) {
+	useMockTracer(t)
+	m := flipBreakfasts(t, 8, 25, nil)
+	if cooked := testutil.ToFloat64(m.Cooked); cooked != 8*25 {
+		t.Errorf("counted %v cooked pancakes, want %d", cooked, 8*25)
+	}
+	if burnt := testutil.ToFloat64(m.Burnt); burnt != 0 {
+		t.Errorf("counted %v burnt pancakes, want 0", burnt)
+	}
+}
+
+func TestFlipPancakesConcurrentCountsBurntExactly(t *testing.T) {
+	useMockTracer(t)
+	// The last pancake of every batch burns, which stops the rest of it
+	m := flipBreakfasts(t, 8, 25, func(cakes []breakfast.Pancake) Option {
+		return WithBurnDetector(BurnDetectorFunc(func(item Cookable, _ time.Duration) bool {
+			return item == Cookable(&cakes[len(cakes)-1])
+		}))
+	})
+	if burnt := testutil.ToFloat64(m.Burnt); burnt != 8 {
+		t.Errorf("counted %v burnt pancakes, want 8", burnt)
+	}
+}
+
+func TestSyrupPancakesConcurrentMixedOutcomes(t *testing.T) {
+	mt := useMockTracer(t)
+
+	var mistakes []breakfast.Pancake
+	ready := SyrupPancakesConcurrent(context.Background(), breakfast.MakePancakes(20), 4, soggyAt(0, 3, 6, 9, 12), WithMistakes(&mistakes))
+	if got := collect(ready); len(got) != 15 {
+		t.Errorf("got %d pancakes, want the 15 good ones", len(got))
+	}
+	if len(mistakes) != 5 {
+		t.Errorf("got %d mistakes, want 5", len(mistakes))
+	}
+
+	waitForEvent(t, mt, "SyrupPancakesConcurrent")
+	if syruped, _ := eventField(mt, "SyrupPancakesConcurrent", "syruped"); syruped != "15" {
+		t.Errorf("SyrupPancakesConcurrent syruped = %q, want 15", syruped)
+	}
+	breakfasttest.AssertSpanCount(t, mt, "SyrupPancake", 20)
+}
+
+func TestFlipPancakesConcurrentContinuesOnError(t *testing.T) {
+	mt := useMockTracer(t)
+	cakes := breakfast.MakePancakes(12)
+	burner := BurnDetectorFunc(func(item Cookable, _ time.Duration) bool {
+		return item == Cookable(&cakes[3]) || item == Cookable(&cakes[11])
+	})
+
+	err := FlipPancakesConcurrent(context.Background(), cakes, 4, cleanFlips(), WithCookDuration(time.Millisecond), WithBurnDetector(burner), WithContinueOnError())
+	var batch *BatchError
+	if !errors.As(err, &batch) {
+		t.Fatalf("got %v, want a *BatchError", err)
+	}
+	var burnt []int
+	for _, err := range batch.Errors() {
+		var b *BurntPancakeError
+		if errors.As(err, &b) {
+			burnt = append(burnt, b.Index)
+		}
+	}
+	if len(burnt) != 2 || burnt[0] != 3 || burnt[1] != 11 {
+		t.Errorf("burnt pancakes %v, want [3 11]", burnt)
+	}
+
+	// Every pancake was cooked and timed, burnt or not
+	spans := breakfasttest.FindSpans(mt, "FlipPancake")
+	if len(spans) != 12 {
+		t.Fatalf("got %d FlipPancake spans, want 12", len(spans))
+	}
+	for _, span := range spans {
+		if span.Tag("cook_time_ms") == nil {
+			t.Errorf("pancake %v has no cook_time_ms", span.Tag("pancake.index"))
+		}
+	}
+}
+
+func TestSyrupPancakesConcurrentGivesUpWithNoReader(t *testing.T) {
+	mt := useMockTracer(t)
+
+	// Nobody ever reads, so the lone worker is stuck with the first pancake
+	ready := SyrupPancakesConcurrent(context.Background(), breakfast.MakePancakes(3), 1, WithDryRun(), WithWriteTimeout(10*time.Millisecond))
+
+	waitForEvent(t, mt, "SyrupPancakesConcurrent")
+	if errField, _ := eventField(mt, "SyrupPancakesConcurrent", "error"); !strings.Contains(errField, ErrNoConsumer.Error()) {
+		t.Errorf("SyrupPancakesConcurrent error = %q, want %q", errField, ErrNoConsumer)
+	}
+	if lost, _ := eventField(mt, "SyrupPancakesConcurrent", "lost_inflight"); lost != "1" {
+		t.Errorf("SyrupPancakesConcurrent lost_inflight = %q, want 1", lost)
+	}
+	if _, ok := <-ready; ok {
+		t.Error("got a pancake after giving up, want the channel closed")
+	}
+}
+
+func TestSyrupPancakesConcurrentCountsPancakesLostOnCancel(t *testing.T) {
+	mt := useMockTracer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Both workers syrup a pancake, then are stuck sending it since nobody
+	// reads
+	var poured sync.WaitGroup
+	poured.Add(2)
+	pour := func(o *options) {
+		o.pour = func(*breakfast.Pancake) error {
+			poured.Done()
+			return nil
+		}
+	}
+	ready := SyrupPancakesConcurrent(ctx, breakfast.MakePancakes(2), 2, pour)
+	poured.Wait()
+	cancel()
+
+	waitForEvent(t, mt, "SyrupPancakesConcurrent")
+	if _, ok := <-ready; ok {
+		t.Error("got a pancake after cancelling, want the channel closed")
+	}
+	if cancelled, _ := eventField(mt, "SyrupPancakesConcurrent", "cancelled"); cancelled != "true" {
+		t.Errorf("SyrupPancakesConcurrent cancelled = %q, want true", cancelled)
+	}
+	if lost, _ := eventField(mt, "SyrupPancakesConcurrent", "lost_inflight"); lost != "2" {
+		t.Errorf("SyrupPancakesConcurrent lost_inflight = %q, want 2", lost)
+	}
+	if syruped, _ := eventField(mt, "SyrupPancakesConcurrent", "syruped"); syruped != "0" {
+		t.Errorf("SyrupPancakesConcurrent syruped = %q, want 0", syruped)
+	}
+}
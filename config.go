@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+)
+
+// Config is kitchen configuration carried in a context, so stages deep in a
+// breakfast can read it without it being passed down to them. Options given
+// to a stage directly take precedence.
+type Config struct {
+	Heat   float64
+	Syrup  string
+	Buffer int
+}
+
+type configKey struct{}
+
+// ContextWithConfig attaches cfg to ctx.
+func ContextWithConfig(ctx context.Context, cfg Config) context.Context {
+	return context.WithValue(ctx, configKey{}, cfg)
+}
+
+// ConfigFromContext returns the Config attached to ctx, with the defaults
+// filled in for anything it leaves unset.
+func ConfigFromContext(ctx context.Context) Config {
+	cfg, _ := ctx.Value(configKey{}).(Config)
+	if cfg.Heat <= 0 {
+		cfg.Heat = defaultHeat
+	}
+	if cfg.Syrup == "" {
+		cfg.Syrup = defaultSyrup
+	}
+	if cfg.Buffer < 0 {
+		cfg.Buffer = 0
+	}
+	return cfg
+}
+
+// contextOptions is newOptions starting from the Config in ctx.
+func contextOptions(ctx context.Context, opts []Option) *options {
+	cfg := ConfigFromContext(ctx)
+	return newOptions(append([]Option{
+		WithHeat(cfg.Heat),
+		WithSyrup(cfg.Syrup),
+		WithBuffer(cfg.Buffer),
+	}, opts...))
+}
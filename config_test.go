@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+func TestConfigFromContextReachesStages(t *testing.T) {
+	ctx := ContextWithConfig(context.Background(), Config{Heat: HeatHigh, Syrup: "blueberry", Buffer: 3})
+	tests := []struct {
+		name   string
+		opts   []Option
+		heat   string
+		syrup  string
+		buffer int
+	}{
+		{name: "from context", heat: "high", syrup: "blueberry", buffer: 3},
+		{name: "options override", opts: []Option{WithHeat(HeatLow), WithSyrup("golden"), WithBuffer(0)}, heat: "low", syrup: "golden", buffer: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := useMockTracer(t)
+			opts := append([]Option{WithDryRun()}, tt.opts...)
+
+			cakes := breakfast.MakePancakes(2)
+			if err := FlipPancakes(ctx, cakes, opts...); err != nil {
+				t.Fatal(err)
+			}
+			for _, span := range breakfasttest.FindSpans(mt, "FlipPancake") {
+				if heat := span.Tag("heat"); heat != tt.heat {
+					t.Errorf("pancake %v cooked at %v heat, want %s", span.Tag("pancake.index"), heat, tt.heat)
+				}
+			}
+
+			ready := SyrupPancakes(ctx, cakes, opts...)
+			if buffer := cap(ready); buffer != tt.buffer {
+				t.Errorf("syruped into a buffer of %d, want %d", buffer, tt.buffer)
+			}
+			collect(ready)
+			waitForEvent(t, mt, "PancakeReady")
+			if syrup, _ := eventField(mt, "PancakeReady", "syrup"); syrup != tt.syrup {
+				t.Errorf("syruped with %q, want %q", syrup, tt.syrup)
+			}
+		})
+	}
+}
+
+func TestConfigFromContextDefaults(t *testing.T) {
+	cfg := ConfigFromContext(ContextWithConfig(context.Background(), Config{Buffer: -1}))
+	if want := (Config{Heat: defaultHeat, Syrup: defaultSyrup}); cfg != want {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+	if cfg := ConfigFromContext(context.Background()); cfg.Heat != defaultHeat || cfg.Syrup != defaultSyrup {
+		t.Errorf("got %+v with no config, want the defaults", cfg)
+	}
+}
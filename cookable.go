@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// Cookable is anything that goes on the griddle and gets flipped.
+// *breakfast.Pancake and *Waffle are both Cookable.
+type Cookable interface {
+	Flip() error
+	IsBurnt() bool
+}
+
+// Cookables can also say how long they take to cook relative to a pancake.
+type cookTimer interface {
+	CookTime(base time.Duration) time.Duration
+}
+
+// FlipAll flips everything, lets it cook and checks nothing burnt, with one
+// span per item.
+func FlipAll[T Cookable](ctx context.Context, items []T, opts ...Option) error {
+	return flipAll(ctx, items, contextOptions(ctx, opts), flipNames{
+		event: "FlipAll",
+		span:  "Flip",
+		tag:   "item",
+	})
+}
+
+// BurnDetector decides whether an item that has cooked for a while is burnt.
+// By default items are asked with IsBurnt, once as they are flipped, and
+// WithBurnDetector swaps in a different strategy, such as a time limit. With
+// WithBurnCheckInterval a detector is asked again at every check, so it
+// should give the same answer for the same cooking time.
+type BurnDetector interface {
+	Burnt(item Cookable, cooked time.Duration) bool
+}
+
+// BurnDetectorFunc lets an ordinary function be a BurnDetector.
+type BurnDetectorFunc func(item Cookable, cooked time.Duration) bool
+
+func (f BurnDetectorFunc) Burnt(item Cookable, cooked time.Duration) bool {
+	return f(item, cooked)
+}
+
+// flipNames are what flipAll calls its event, spans and span tags
+type flipNames struct {
+	event, span, tag string
+}
+
+// What FlipPancakes and FlipPancakesConcurrent call theirs
+var pancakeFlips = flipNames{
+	event: "FlipPancakes",
+	span:  "FlipPancake",
+	tag:   "pancake",
+}
+
+func (n flipNames) indexTag() string { return n.tag + ".index" }
+func (n flipNames) stateTag() string { return n.tag + ".state" }
+
+func flipAll[T Cookable](ctx context.Context, items []T, o *options, names flipNames) (err error) {
+	// Create an EventInProgress - eip - for the whole batch
+	eip := log.EventBegin(ctx, names.event, eventFields(ctx, logging.LoggableMap{"heat": o.heat}))
+	defer func() {
+		if err != nil {
+			eip.SetError(err)
+		}
+		eip.Done()
+	}()
+
+	// Give each item its own span so the trace shows which one failed
+	var flipped []*cookItem
+	defer func() {
+		for _, c := range flipped {
+			c.span.Finish()
+		}
+	}()
+
+	// Stop cooking as soon as whoever asked no longer wants it
+	cancelled := func() error {
+		select {
+		case <-ctx.Done():
+			eip.Append(logging.LoggableMap{"cancelled": true})
+			return ctx.Err()
+		default:
+			return nil
+		}
+	}
+
+	// What went wrong with each item, when carrying on regardless
+	var failed []error
+	var cooking []*cookItem
+	for p := range items {
+		if err := cancelled(); err != nil {
+			return err
+		}
+		c, err := flipItem(ctx, o, names, p, items[p])
+		flipped = append(flipped, c)
+		if err != nil {
+			if o.continueOnError {
+				failed = append(failed, fmt.Errorf("%s %d: %w", names.tag, p, err))
+				continue
+			}
+			return err
+		}
+		cooking = append(cooking, c)
+	}
+
+	// Let everything cook, as long as the slowest item needs, unless we run
+	// out of time first
+	cookFor := o.cookTime()
+	for _, c := range cooking {
+		if d := o.cookTimeOf(c.item); d > cookFor {
+			cookFor = d
+		}
+	}
+	early, err := o.cook(ctx, cookFor, cooking)
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			eip.Append(logging.LoggableMap{"timeout": true})
+		} else {
+			eip.Append(logging.LoggableMap{"cancelled": true})
+		}
+		return fmt.Errorf("cooking %ss: %w", names.tag, err)
+	}
+	if early {
+		eip.Append(logging.LoggableMap{"pulled_early": true})
+	}
+
+	for _, c := range cooking {
+		if err := cancelled(); err != nil {
+			return err
+		}
+		if err := c.done(o); err != nil {
+			if o.continueOnError {
+				failed = append(failed, err)
+				continue
+			}
+			return fmt.Errorf("flipping %ss: %w", names.tag, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("flipping %ss: %w", names.tag, &BatchError{errs: failed})
+	}
+	return nil
+}
+
+// cookItem is an item on the griddle, under its own span. flipAll cooks a
+// batch of them together, FlipPancakesConcurrent's workers one at a time.
+type cookItem struct {
+	names     flipNames
+	index     int
+	item      Cookable
+	span      opentracing.Span
+	flippedAt time.Time
+	// Whether the item has been checked for burning, and whether it burnt.
+	// Without a BurnDetector whether it burns is decided as it is flipped.
+	checked, burnt bool
+}
+
+// flipItem starts a span for the item at index and flips it. An item that
+// won't flip stays raw, with the error on its span.
+func flipItem(ctx context.Context, o *options, names flipNames, index int, item Cookable) (*cookItem, error) {
+	c := &cookItem{names: names, index: index, item: item}
+	c.span, ctx = startSpan(ctx, names.span)
+	c.span.SetTag(names.indexTag(), index)
+	c.span.SetTag("heat", heatLevel(o.heat))
+	if err := o.flip(ctx, c.span, item); err != nil {
+		c.span.SetTag(names.stateTag(), "raw")
+		ext.Error.Set(c.span, true)
+		c.span.LogFields(otlog.Error(err))
+		return c, err
+	}
+	c.span.SetTag(names.stateTag(), "flipped")
+	c.flippedAt = o.clock.Now()
+	if o.burnDetector == nil {
+		c.burnt = o.isBurnt(item, 0)
+	}
+	o.flipped(index, item)
+	c.span.LogFields(
+		otlog.String("event", "flipped"),
+		otlog.Int(names.indexTag(), index),
+		otlog.String(names.stateTag(), "flipped"),
+	)
+	return c, nil
+}
+
+// cookTimeOf is how long item needs on the griddle, which is longer than a
+// pancake for a cookTimer that says so.
+func (o *options) cookTimeOf(item Cookable) time.Duration {
+	d := o.cookTime()
+	if ct, ok := item.(cookTimer); ok {
+		if t := ct.CookTime(o.cookTime()); t > d {
+			d = t
+		}
+	}
+	return d
+}
+
+// cook lets the items cook for cookFor. With a burn check interval it looks
+// for burning every interval and stops as soon as anything burns rather than
+// cooking to the end, reporting whether that was early.
+func (o *options) cook(ctx context.Context, cookFor time.Duration, items []*cookItem) (early bool, err error) {
+	for waited := time.Duration(0); ; {
+		step := cookFor - waited
+		if o.burnCheckInterval > 0 && o.burnCheckInterval < step {
+			step = o.burnCheckInterval
+		}
+		select {
+		case <-o.clock.After(step):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+		waited += step
+		if o.burnCheckInterval <= 0 {
+			return false, nil
+		}
+
+		found := false
+		for _, c := range items {
+			if c.check(o) {
+				found = true
+			}
+		}
+		if found || waited >= cookFor {
+			return found && waited < cookFor, nil
+		}
+	}
+}
+
+// check looks for burning, remembering what it found for done. Only a
+// BurnDetector is asked again, so checking often doesn't make an item any
+// likelier to burn.
+func (c *cookItem) check(o *options) bool {
+	c.checked = true
+	if o.burnDetector != nil {
+		c.burnt = o.isBurnt(c.item, o.clock.Now().Sub(c.flippedAt))
+	}
+	return c.burnt
+}
+
+// done takes the item off the griddle, tagging its span with how long it
+// cooked and whether it burnt, and counts it. A burnt item fails with a
+// *BurntPancakeError.
+func (c *cookItem) done(o *options) error {
+	indexTag, stateTag := c.names.indexTag(), c.names.stateTag()
+	cooked := o.clock.Now().Sub(c.flippedAt)
+	c.span.SetTag("cook_time_ms", cooked.Milliseconds())
+	o.metrics.observe("flip", cooked)
+	c.span.LogFields(
+		otlog.String("event", "cooked"),
+		otlog.Int(indexTag, c.index),
+		otlog.String("cook_time", cooked.String()),
+	)
+	// Polling may already have checked, so don't check again
+	if !c.checked {
+		c.check(o)
+	}
+
+	state := "cooked"
+	if c.burnt {
+		state = "burnt"
+		ext.Error.Set(c.span, true)
+	}
+	c.span.SetTag(stateTag, state)
+	c.span.LogFields(
+		otlog.String("event", "burn_check"),
+		otlog.Int(indexTag, c.index),
+		otlog.String(stateTag, state),
+	)
+	if c.burnt {
+		o.burnt()
+		return &BurntPancakeError{Index: c.index}
+	}
+	o.cooked()
+	return nil
+}
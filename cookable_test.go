@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+// neverBurns has nothing burn, so outcomes don't depend on the pancakes.
+func neverBurns() Option {
+	return WithBurnDetector(BurnDetectorFunc(func(Cookable, time.Duration) bool { return false }))
+}
+
+func TestFlipAllPancakesAndWaffles(t *testing.T) {
+	mt := useMockTracer(t)
+
+	cake := breakfast.MakePancakes(1)[0]
+	// Twice the squares, so twice as long as the pancake
+	waffle := &Waffle{Squares: 2 * standardSquares}
+	items := []Cookable{&cake, waffle}
+	if err := FlipAll(context.Background(), items, cleanFlips(), neverBurns(), WithCookDuration(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := breakfasttest.FindSpans(mt, "Flip")
+	if len(spans) != 2 {
+		t.Fatalf("got %d Flip spans, want one per item", len(spans))
+	}
+	// Everything cooks for as long as the waffle needs
+	for _, span := range spans {
+		if ms, _ := span.Tag("cook_time_ms").(int64); ms < 20 {
+			t.Errorf("item %v cooked for %dms, want at least the waffle's 20ms", span.Tag("item.index"), ms)
+		}
+		if state := span.Tag("item.state"); state != "cooked" {
+			t.Errorf("item %v state = %v, want cooked", span.Tag("item.index"), state)
+		}
+	}
+}
+
+// steadyCake flips fine and never burns by itself, so whether it burns is
+// down to the kitchen alone.
+type steadyCake struct{}
+
+func (*steadyCake) Flip() error   { return nil }
+func (*steadyCake) IsBurnt() bool { return false }
+
+// burntAt cooks n steady cakes at heat with the seeded source and any other
+// opts, carrying on past burns, and returns which of them burnt.
+func burntAt(t *testing.T, n int, heat float64, seed int64, opts ...Option) []int {
+	t.Helper()
+	items := make([]*steadyCake, n)
+	for i := range items {
+		items[i] = &steadyCake{}
+	}
+	opts = append([]Option{WithHeat(heat), WithRandSource(rand.NewSource(seed)), WithCookDuration(time.Nanosecond), WithContinueOnError()}, opts...)
+	err := FlipAll(context.Background(), items, opts...)
+	if err == nil {
+		return nil
+	}
+	var batch *BatchError
+	if !errors.As(err, &batch) {
+		t.Fatalf("got %v, want a *BatchError", err)
+	}
+	var burnt []int
+	for _, err := range batch.Errors() {
+		var b *BurntPancakeError
+		if !errors.As(err, &b) {
+			t.Fatalf("got %v, want only burns", err)
+		}
+		burnt = append(burnt, b.Index)
+	}
+	return burnt
+}
+
+func TestHighHeatBurnsMore(t *testing.T) {
+	useMockTracer(t)
+
+	low, high := 0, 0
+	for seed := int64(1); seed <= 10; seed++ {
+		low += len(burntAt(t, 50, HeatLow, seed))
+		high += len(burntAt(t, 50, HeatHigh, seed))
+	}
+	if low != 0 {
+		t.Errorf("%d of 500 burnt on low heat, want none", low)
+	}
+	// One step above medium burns a fifth of them, give or take
+	if high < 50 || high > 150 {
+		t.Errorf("%d of 500 burnt on high heat, want about 100", high)
+	}
+}
+
+func TestSeededBurnsRepeat(t *testing.T) {
+	useMockTracer(t)
+
+	first, again := burntAt(t, 50, HeatHigh, 42), burntAt(t, 50, HeatHigh, 42)
+	if len(first) == 0 {
+		t.Fatal("nothing burnt, so nothing to compare")
+	}
+	if len(first) != len(again) {
+		t.Fatalf("burnt %v, then %v with the same seed", first, again)
+	}
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("burnt %v, then %v with the same seed", first, again)
+		}
+	}
+}
+
+func TestBurnCheckIntervalDoesNotCompound(t *testing.T) {
+	useMockTracer(t)
+
+	// Checking every millisecond of a 5ms cook must burn the same cakes as
+	// checking once at the end
+	once := burntAt(t, 50, HeatHigh, 7)
+	polled := burntAt(t, 50, HeatHigh, 7, WithCookDuration(5*time.Millisecond), WithBurnCheckInterval(time.Millisecond))
+	if len(once) == 0 {
+		t.Fatal("nothing burnt, so nothing to compare")
+	}
+	if len(once) != len(polled) {
+		t.Fatalf("burnt %v checking once, %v checking often", once, polled)
+	}
+	for i := range once {
+		if once[i] != polled[i] {
+			t.Fatalf("burnt %v checking once, %v checking often", once, polled)
+		}
+	}
+}
+
+func TestBurnCheckIntervalPullsAtFirstBurn(t *testing.T) {
+	mt := useMockTracer(t)
+	clock := NewFakeClock(time.Unix(0, 0))
+
+	// Burns once it has cooked 300ms, well before its second is up
+	detector := BurnDetectorFunc(func(_ Cookable, cooked time.Duration) bool {
+		return cooked >= 300*time.Millisecond
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- FlipAll(context.Background(), []*steadyCake{{}}, WithClock(clock), WithCookDuration(time.Second), WithBurnCheckInterval(100*time.Millisecond), WithBurnDetector(detector))
+	}()
+	for i := 0; i < 3; i++ {
+		waitForSleepers(t, clock, 1)
+		clock.Advance(100 * time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		var burnt *BurntPancakeError
+		if !errors.As(err, &burnt) {
+			t.Fatalf("got %v, want a *BurntPancakeError", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("still cooking after it burnt at 300ms")
+	}
+	span := breakfasttest.AssertSpanExists(t, mt, "Flip")
+	if took := span.Tag("cook_time_ms"); took != int64(300) {
+		t.Errorf("cooked for %vms, want 300", took)
+	}
+	if early, _ := eventField(mt, "FlipAll", "pulled_early"); early != "true" {
+		t.Errorf("FlipAll pulled_early = %q, want true", early)
+	}
+}
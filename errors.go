@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrBurntPancake = errors.New("burnt pancake")
+	ErrSoggyPancake = errors.New("soggy pancake")
+
+	ErrTooManyMistakes = errors.New("too many mistakes, giving up on the batch")
+	ErrNoConsumer      = errors.New("nobody is taking the pancakes")
+	// Why syruping stops when whoever is eating has had enough, which isn't
+	// a failure
+	ErrCustomerLeft = errors.New("customer left")
+)
+
+// BurntPancakeError reports which pancake in a batch burnt.
+// It matches ErrBurntPancake with errors.Is.
+type BurntPancakeError struct {
+	Index int
+}
+
+func (e *BurntPancakeError) Error() string {
+	return fmt.Sprintf("pancake %d: %s", e.Index, ErrBurntPancake)
+}
+
+func (e *BurntPancakeError) Unwrap() error {
+	return ErrBurntPancake
+}
+
+// BatchError collects every failure in a batch cooked with
+// WithContinueOnError, one per failed item. It matches any of them with
+// errors.Is and errors.As.
+type BatchError struct {
+	errs []error
+}
+
+func (e *BatchError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d failed: %s", len(e.errs), strings.Join(msgs, "; "))
+}
+
+// Errors returns the failures in the order the items were cooked.
+func (e *BatchError) Errors() []error {
+	return e.errs
+}
+
+func (e *BatchError) Unwrap() []error {
+	return e.errs
+}
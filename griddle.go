@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ext "github.com/opentracing/opentracing-go/ext"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+var (
+	ErrGriddleTooSmall  = errors.New("griddle has no room for pancakes")
+	ErrInvalidBatchSize = errors.New("batch size must be positive")
+)
+
+// Griddle cooks pancakes in batches of at most Capacity.
+type Griddle struct {
+	Capacity int
+}
+
+// Cook flips the pancakes one batch at a time, each batch under its own span
+// so the trace shows where the batches start and end. The pancakes cooked
+// before any error are returned along with it.
+func (g *Griddle) Cook(ctx context.Context, cakes []breakfast.Pancake, opts ...Option) ([]breakfast.Pancake, error) {
+	if g.Capacity <= 0 {
+		return nil, ErrGriddleTooSmall
+	}
+
+	o := contextOptions(ctx, opts)
+	began := o.clock.Now()
+	batches, _ := SplitBatches(cakes, g.Capacity)
+	start := 0
+	for b, batch := range batches {
+		span, bctx := startSpan(ctx, "CookBatch")
+		span.SetTag("batch.index", b)
+		span.SetTag("batch.size", len(batch))
+		err := FlipPancakes(bctx, batch, opts...)
+		if err != nil {
+			ext.Error.Set(span, true)
+		}
+		// Compare the estimate with how long the batch really took, to
+		// see how far off it is
+		span.SetTag("ready.estimated_ms", EstimatedReady(start, g.Capacity, o.cookTime()).Milliseconds())
+		span.SetTag("ready.actual_ms", o.clock.Now().Sub(began).Milliseconds())
+		span.Finish()
+		if err != nil {
+			return cakes[:start], err
+		}
+		start += len(batch)
+	}
+	return cakes, nil
+}
+
+// SplitBatches splits cakes into batches of size, the last one smaller if
+// they don't divide evenly. The batches share cakes' backing array.
+func SplitBatches(cakes []breakfast.Pancake, size int) ([][]breakfast.Pancake, error) {
+	if size <= 0 {
+		return nil, ErrInvalidBatchSize
+	}
+	batches := make([][]breakfast.Pancake, 0, (len(cakes)+size-1)/size)
+	for start := 0; start < len(cakes); start += size {
+		end := min(start+size, len(cakes))
+		batches = append(batches, cakes[start:end:end])
+	}
+	return batches, nil
+}
+
+// EstimatedReady estimates how long the pancake at index in the queue will
+// take to be ready, if a griddle of the given capacity cooks the queue in
+// batches taking avgCook each.
+func EstimatedReady(index, capacity int, avgCook time.Duration) time.Duration {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return time.Duration(index/capacity+1) * avgCook
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+func TestGriddleCooksInBatches(t *testing.T) {
+	mt := useMockTracer(t)
+
+	g := &Griddle{Capacity: 3}
+	cooked, err := g.Cook(context.Background(), breakfast.MakePancakes(7), WithDryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cooked) != 7 {
+		t.Errorf("cooked %d pancakes, want 7", len(cooked))
+	}
+
+	spans := breakfasttest.FindSpans(mt, "CookBatch")
+	if len(spans) != 3 {
+		t.Fatalf("got %d CookBatch spans, want 3", len(spans))
+	}
+	want := []int{3, 3, 1}
+	for _, span := range spans {
+		b, _ := span.Tag("batch.index").(int)
+		if size := span.Tag("batch.size"); size != want[b] {
+			t.Errorf("batch %d has %v pancakes, want %d", b, size, want[b])
+		}
+	}
+}
+
+func TestGriddleTooSmall(t *testing.T) {
+	useMockTracer(t)
+
+	g := &Griddle{}
+	if _, err := g.Cook(context.Background(), breakfast.MakePancakes(3), WithDryRun()); !errors.Is(err, ErrGriddleTooSmall) {
+		t.Errorf("got %v, want %v", err, ErrGriddleTooSmall)
+	}
+}
+
+func TestSplitBatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		cakes int
+		size  int
+		sizes []int
+		err   error
+	}{
+		{name: "even split", cakes: 6, size: 3, sizes: []int{3, 3}},
+		{name: "remainder", cakes: 7, size: 3, sizes: []int{3, 3, 1}},
+		{name: "size beyond batch", cakes: 2, size: 5, sizes: []int{2}},
+		{name: "no pancakes", cakes: 0, size: 3, sizes: []int{}},
+		{name: "zero size", cakes: 3, size: 0, err: ErrInvalidBatchSize},
+		{name: "negative size", cakes: 3, size: -1, err: ErrInvalidBatchSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cakes := breakfast.MakePancakes(tt.cakes)
+			batches, err := SplitBatches(cakes, tt.size)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if len(batches) != len(tt.sizes) {
+				t.Fatalf("got %d batches, want %d", len(batches), len(tt.sizes))
+			}
+			next := 0
+			for b, batch := range batches {
+				if len(batch) != tt.sizes[b] {
+					t.Errorf("batch %d has %d pancakes, want %d", b, len(batch), tt.sizes[b])
+				}
+				// Batches share the pancakes, in order, without copying
+				for i := range batch {
+					if &batch[i] != &cakes[next] {
+						t.Errorf("batch %d pancake %d isn't pancake %d", b, i, next)
+					}
+					next++
+				}
+			}
+		})
+	}
+}
+
+func TestEstimatedReady(t *testing.T) {
+	const cook = time.Minute
+	tests := []struct {
+		index, capacity int
+		want            time.Duration
+	}{
+		// The first batch is ready after one cook
+		{index: 0, capacity: 4, want: cook},
+		{index: 3, capacity: 4, want: cook},
+		// Each further batch adds another
+		{index: 4, capacity: 4, want: 2 * cook},
+		{index: 11, capacity: 4, want: 3 * cook},
+		// A bigger griddle gets through the queue sooner
+		{index: 11, capacity: 12, want: cook},
+		{index: 11, capacity: 1, want: 12 * cook},
+		// A griddle with no room still cooks one at a time
+		{index: 2, capacity: 0, want: 3 * cook},
+	}
+	for _, tt := range tests {
+		if got := EstimatedReady(tt.index, tt.capacity, cook); got != tt.want {
+			t.Errorf("EstimatedReady(%d, %d, %s) = %s, want %s", tt.index, tt.capacity, cook, got, tt.want)
+		}
+	}
+}
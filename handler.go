@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+)
+
+// orderRequest is the body of a breakfast order sent to OrderHandler. Every
+// field is optional, but pancakes must be between 1 and MaxBatchSize if set.
+type orderRequest struct {
+	Pancakes int    `json:"pancakes"`
+	Syrup    string `json:"syrup"`
+	Coffee   *int   `json:"coffee"`
+	Table    int    `json:"table"`
+}
+
+func (req orderRequest) options() []Option {
+	var opts []Option
+	// A bad count is left for the handler to refuse
+	if req.Pancakes != 0 {
+		opts = append(opts, WithPancakeCount(req.Pancakes))
+	}
+	if req.Syrup != "" {
+		opts = append(opts, WithSyrup(req.Syrup))
+	}
+	if req.Coffee != nil {
+		opts = append(opts, WithCoffee(*req.Coffee))
+	}
+	if req.Table > 0 {
+		opts = append(opts, WithTableNumber(req.Table))
+	}
+	return opts
+}
+
+// orderResponse describes the breakfast OrderHandler served.
+type orderResponse struct {
+	Pancakes   int    `json:"pancakes"`
+	Syrup      string `json:"syrup"`
+	Coffee     int    `json:"coffee"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OrderHandler serves a breakfast in the kitchen for each POSTed order. A
+// trace sent in the request headers is continued by a server span for the
+// order, and a fresh trace is started when there is none or it can't be read.
+// The order's trace is written to the response headers. Once the kitchen is
+// shut down orders are turned away as unavailable.
+func (k *Kitchen) OrderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "orders must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tracer := opentracing.GlobalTracer()
+	var spanOpts []opentracing.StartSpanOption
+	client, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header))
+	if err == nil {
+		spanOpts = append(spanOpts, ext.RPCServerOption(client))
+	} else if err != opentracing.ErrSpanContextNotFound {
+		log.Warningf("Ignoring the order's trace: %s", err)
+	}
+	span := StartRootSpan("OrderBreakfast", spanOpts...)
+	defer span.Finish()
+	ext.HTTPMethod.Set(span, r.Method)
+	ext.HTTPUrl.Set(span, r.URL.String())
+
+	reply := func(status int, resp any) {
+		ext.HTTPStatusCode.Set(span, uint16(status))
+		if status >= http.StatusInternalServerError {
+			ext.Error.Set(span, true)
+		}
+		tracer.Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(w.Header()))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+
+	var req orderRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			reply(http.StatusBadRequest, orderResponse{Error: err.Error()})
+			return
+		}
+	}
+	opts := req.options()
+	o := newOptions(opts)
+	if err := checkCount(o.pancakes); err != nil {
+		reply(http.StatusBadRequest, orderResponse{Pancakes: o.pancakes, Error: err.Error()})
+		return
+	}
+
+	// The breakfast continues the order's trace
+	ctx := contextWithOrder(r.Context(), span.Context())
+	summary, err := k.ServeWithSummary(ctx, opts...)
+	if err == ErrKitchenClosed {
+		reply(http.StatusServiceUnavailable, orderResponse{Pancakes: o.pancakes, Error: err.Error()})
+		return
+	}
+	logBreakfast(ctx, err, o.pancakes, summary)
+
+	resp := orderResponse{
+		Pancakes:   o.pancakes,
+		Syrup:      o.syrup,
+		Coffee:     o.coffee,
+		DurationMS: summary.Duration.Milliseconds(),
+	}
+	status := http.StatusOK
+	if err != nil {
+		resp.Error = err.Error()
+		status = http.StatusInternalServerError
+	}
+	reply(status, resp)
+}
+
+// StartOrderServer takes breakfast orders for the kitchen at /order on addr.
+// The returned server is already listening; Shutdown or Close it when done.
+func StartOrderServer(addr string, k *Kitchen) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/order", k.OrderHandler)
+	return startServer("Order", addr, mux)
+}
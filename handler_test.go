@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	mocktracer "github.com/opentracing/opentracing-go/mocktracer"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+func TestOrderHandlerRefusesBadCounts(t *testing.T) {
+	for _, body := range []string{`{"pancakes": -1}`, `{"pancakes": 1000000}`} {
+		mt := useMockTracer(t)
+		rec := httptest.NewRecorder()
+		NewKitchen(1).OrderHandler(rec, httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(body)))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+		if got := breakfasttest.SpanTag(mt, "OrderBreakfast", "http.status_code"); got != uint16(http.StatusBadRequest) {
+			t.Errorf("%s: OrderBreakfast http.status_code = %v, want 400", body, got)
+		}
+		breakfasttest.AssertSpanCount(t, mt, "ServeHotCakes", 0)
+	}
+}
+
+func TestOrderHandlerContinuesTheClientsTrace(t *testing.T) {
+	mt := useMockTracer(t)
+	client := mt.StartSpan("PlaceOrder")
+	// An invalid count keeps the order from being cooked
+	req := httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(`{"pancakes": -1}`))
+	mt.Inject(client.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(req.Header))
+	client.Finish()
+
+	rec := httptest.NewRecorder()
+	NewKitchen(1).OrderHandler(rec, req)
+
+	server := breakfasttest.AssertSpanExists(t, mt, "OrderBreakfast")
+	if server == nil {
+		return
+	}
+	if want := client.Context().(mocktracer.MockSpanContext).SpanID; server.ParentID != want {
+		t.Errorf("OrderBreakfast has parent %d, want the client's span %d", server.ParentID, want)
+	}
+	if resp, err := mt.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(rec.Header())); err != nil {
+		t.Errorf("response has no trace: %s", err)
+	} else if got := resp.(mocktracer.MockSpanContext).SpanID; got != server.SpanContext.SpanID {
+		t.Errorf("response carries span %d, want OrderBreakfast's %d", got, server.SpanContext.SpanID)
+	}
+}
+
+func TestOrderHandlerOnlyTakesPosts(t *testing.T) {
+	useMockTracer(t)
+	rec := httptest.NewRecorder()
+	NewKitchen(1).OrderHandler(rec, httptest.NewRequest(http.MethodGet, "/order", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestOrderHandlerServesBreakfast(t *testing.T) {
+	mt := useMockTracer(t)
+	rec := httptest.NewRecorder()
+	NewKitchen(1, WithDryRun()).OrderHandler(rec, httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(`{"pancakes": 2, "coffee": 0}`)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var resp orderResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Pancakes != 2 || resp.Error != "" {
+		t.Errorf("got %+v, want 2 pancakes and no error", resp)
+	}
+
+	order := breakfasttest.AssertSpanExists(t, mt, "OrderBreakfast")
+	root := breakfasttest.AssertSpanExists(t, mt, "ServeHotCakes")
+	if order == nil || root == nil {
+		return
+	}
+	if root.ParentID != order.SpanContext.SpanID {
+		t.Errorf("ServeHotCakes has parent %d, want OrderBreakfast's span %d", root.ParentID, order.SpanContext.SpanID)
+	}
+	// Cooked on the kitchen's griddle
+	breakfasttest.AssertSpanCount(t, mt, "griddle-0", 1)
+}
+
+func TestOrderHandlerTurnsAwayOrdersWhenClosed(t *testing.T) {
+	useMockTracer(t)
+	k := NewKitchen(1, WithDryRun())
+	if err := k.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	k.OrderHandler(rec, httptest.NewRequest(http.MethodPost, "/order", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
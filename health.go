@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// What the health endpoints report
+var (
+	tracerReady atomic.Bool
+	serving     atomic.Bool
+)
+
+// StartHealthServer serves the kitchen's health checks on addr:
+//
+//	/healthz is OK once the tracer is initialized
+//	/readyz is OK while the serve loop is running
+//
+// The returned server is already listening; Shutdown or Close it when done.
+func StartHealthServer(addr string) (*http.Server, error) {
+	return startServer("Health", addr, healthMux())
+}
+
+// healthMux routes the health checks to their handlers.
+func healthMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthCheck(&tracerReady, "tracer not initialized"))
+	mux.HandleFunc("/readyz", healthCheck(&serving, "not serving breakfast"))
+	return mux
+}
+
+func healthCheck(ok *atomic.Bool, why string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ok.Load() {
+			http.Error(w, why, http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// startServer listens on addr and then serves h in the background, logging
+// why if it stops for any reason other than being shut down. name says which
+// server it was in the log.
+func startServer(name, addr string, h http.Handler) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{Handler: h}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorf("%s server stopped: %s", name, err)
+		}
+	}()
+	return srv, nil
+}
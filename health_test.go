@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthzWaitsForTheTracer(t *testing.T) {
+	t.Cleanup(func() { tracerReady.Store(false) })
+	mux := healthMux()
+	check := func() int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		return rec.Code
+	}
+
+	tracerReady.Store(false)
+	if code := check(); code != http.StatusServiceUnavailable {
+		t.Errorf("before the tracer is ready got %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	tracerReady.Store(true)
+	if code := check(); code != http.StatusOK {
+		t.Errorf("once the tracer is ready got %d, want %d", code, http.StatusOK)
+	}
+}
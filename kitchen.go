@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	ext "github.com/opentracing/opentracing-go/ext"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+var ErrKitchenClosed = errors.New("kitchen is closed")
+
+// How many pancakes fit on each of a kitchen's griddles
+const griddleCapacity = 4
+
+// Kitchen cooks on several griddles at once.
+type Kitchen struct {
+	griddles []*Griddle
+	// Applied to everything the kitchen cooks, before the caller's own
+	opts []Option
+
+	mu     sync.Mutex
+	closed bool
+	// Breakfasts being served right now
+	active sync.WaitGroup
+}
+
+// NewKitchen returns a kitchen with the given number of griddles, at least
+// one. opts apply to everything it cooks, e.g. WithMaxConcurrency to limit
+// how much goes on at once across all its griddles.
+func NewKitchen(griddles int, opts ...Option) *Kitchen {
+	if griddles < 1 {
+		griddles = 1
+	}
+	k := &Kitchen{opts: opts}
+	for g := 0; g < griddles; g++ {
+		k.griddles = append(k.griddles, &Griddle{Capacity: griddleCapacity})
+	}
+	return k
+}
+
+// Cook shares the pancakes out evenly between the griddles and cooks them all
+// at once. Each griddle works under its own span, griddle-0, griddle-1 and so
+// on, so the trace shows them as parallel subtrees. If any griddle fails the
+// others are stopped and the first error is returned along with whatever was
+// cooked.
+func (k *Kitchen) Cook(ctx context.Context, cakes []breakfast.Pancake, opts ...Option) ([]breakfast.Pancake, error) {
+	return k.cook(ctx, cakes, k.options(opts))
+}
+
+// cook is Cook with the kitchen's options already in opts.
+func (k *Kitchen) cook(ctx context.Context, cakes []breakfast.Pancake, opts []Option) ([]breakfast.Pancake, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	n := len(k.griddles)
+	cooked := make([][]breakfast.Pancake, n)
+	var (
+		wg       sync.WaitGroup
+		failed   sync.Once
+		firstErr error
+	)
+	for g, griddle := range k.griddles {
+		// Griddle g gets its share of the pancakes, the first few griddles
+		// taking one extra when they don't divide evenly
+		start := g*(len(cakes)/n) + min(g, len(cakes)%n)
+		end := start + len(cakes)/n
+		if g < len(cakes)%n {
+			end++
+		}
+
+		wg.Add(1)
+		go func(g int, griddle *Griddle, share []breakfast.Pancake) {
+			defer wg.Done()
+			span, gctx := startSpan(ctx, fmt.Sprintf("griddle-%d", g))
+			defer span.Finish()
+			span.SetTag("griddle.pancakes", len(share))
+			if len(share) == 0 {
+				return
+			}
+
+			var err error
+			cooked[g], err = griddle.Cook(gctx, share, opts...)
+			span.SetTag("griddle.cooked", len(cooked[g]))
+			if err != nil {
+				ext.Error.Set(span, true)
+				// Only the first failure is the real cause, the rest will
+				// just have been cancelled
+				failed.Do(func() { firstErr = err })
+				cancel()
+			}
+		}(g, griddle, cakes[start:end])
+	}
+	wg.Wait()
+
+	var all []breakfast.Pancake
+	for g := range cooked {
+		all = append(all, cooked[g]...)
+	}
+	return all, firstErr
+}
+
+// Serve serves a breakfast, its pancakes cooked on the kitchen's griddles as
+// Cook does, or returns ErrKitchenClosed once Shutdown has been called.
+func (k *Kitchen) Serve(ctx context.Context, opts ...Option) error {
+	_, err := k.ServeWithSummary(ctx, opts...)
+	return err
+}
+
+// ServeWithSummary serves a breakfast like Serve, and also returns its
+// summary as ServeBreakfastWithSummary does.
+func (k *Kitchen) ServeWithSummary(ctx context.Context, opts ...Option) (BreakfastSummary, error) {
+	k.mu.Lock()
+	if k.closed {
+		k.mu.Unlock()
+		return BreakfastSummary{}, ErrKitchenClosed
+	}
+	k.active.Add(1)
+	k.mu.Unlock()
+	defer k.active.Done()
+
+	opts = k.options(opts)
+	return ServeBreakfastWithSummary(ctx, append(opts[:len(opts):len(opts)], withKitchen(k))...)
+}
+
+// withKitchen cooks the pancakes on k's griddles.
+func withKitchen(k *Kitchen) Option {
+	return func(o *options) {
+		o.kitchen = k
+	}
+}
+
+// options puts the kitchen's options before opts, so opts win.
+func (k *Kitchen) options(opts []Option) []Option {
+	if len(k.opts) == 0 {
+		return opts
+	}
+	return append(k.opts[:len(k.opts):len(k.opts)], opts...)
+}
+
+// Shutdown stops the kitchen taking new breakfasts and waits for the ones
+// being served to finish. If ctx ends first its error is returned and those
+// breakfasts are left to finish on their own.
+func (k *Kitchen) Shutdown(ctx context.Context) error {
+	k.mu.Lock()
+	k.closed = true
+	k.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		k.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+func TestKitchenServesOnItsGriddles(t *testing.T) {
+	mt := useMockTracer(t)
+
+	k := NewKitchen(2, WithDryRun())
+	summary, err := k.ServeWithSummary(context.Background(), WithPancakeCount(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Served != 10 {
+		t.Errorf("served %d pancakes, want 10", summary.Served)
+	}
+	for _, name := range []string{"griddle-0", "griddle-1"} {
+		if n := breakfasttest.SpanTag(mt, name, "griddle.pancakes"); n != 5 {
+			t.Errorf("%s cooked %v pancakes, want half of them", name, n)
+		}
+	}
+	// Each griddle takes 4 at a time, so cooks its 5 in two batches
+	breakfasttest.AssertSpanCount(t, mt, "CookBatch", 4)
+	breakfasttest.AssertSpanCount(t, mt, "FlipPancake", 10)
+}
+
+func TestKitchenShutdownWaitsForBreakfast(t *testing.T) {
+	useMockTracer(t)
+	clock := NewFakeClock(time.Unix(0, 0))
+	k := NewKitchen(1, cleanFlips(), neverBurns(), soggyAt(), WithCoffee(0), WithCookDuration(time.Minute), WithClock(clock))
+
+	served := make(chan error, 1)
+	go func() { served <- k.Serve(context.Background()) }()
+	// The pancakes are on the griddle until the clock moves
+	waitForSleepers(t, clock, 1)
+
+	shut := make(chan error, 1)
+	go func() { shut <- k.Shutdown(context.Background()) }()
+	select {
+	case err := <-shut:
+		t.Fatalf("Shutdown returned %v with breakfast still cooking", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clock.Advance(time.Minute)
+	if err := <-served; err != nil {
+		t.Fatalf("breakfast in progress when shutting down: %v", err)
+	}
+	select {
+	case err := <-shut:
+		if err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown didn't return once breakfast was served")
+	}
+
+	if err := k.Serve(context.Background()); !errors.Is(err, ErrKitchenClosed) {
+		t.Errorf("serving after shutdown got %v, want %v", err, ErrKitchenClosed)
+	}
+}
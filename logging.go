@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	logging "github.com/ipfs/go-log"
+	lwriter "github.com/ipfs/go-log/writer"
+	opentracing "github.com/opentracing/opentracing-go"
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+// Set to "json" to log breakfast outcomes as JSON events instead of text
+const logFormatEnv = "BREAKFAST_LOG_FORMAT"
+
+var jsonLogs bool
+
+// setupLogging shows info level logs and, if asked for, writes go-log's JSON
+// event log to stdout.
+func setupLogging() {
+	logging.SetLogLevel("breakfast", "info")
+	if os.Getenv(logFormatEnv) == "json" {
+		jsonLogs = true
+		lwriter.WriterGroup.AddWriter(os.Stdout)
+	}
+}
+
+// logBreakfast reports how a breakfast went, at info level on success and
+// error level when it was ruined. It is logged with the breakfast's trace,
+// from the summary, or else the trace in ctx.
+func logBreakfast(ctx context.Context, err error, pancakes int, s BreakfastSummary) {
+	took := s.Duration
+	trace, traced := s.TraceID, s.TraceID != ""
+	if !traced {
+		trace, traced = traceID(ctx)
+	}
+
+	if jsonLogs {
+		fields := eventFields(ctx, logging.LoggableMap{
+			"pancakes":    pancakes,
+			"duration_ms": took.Milliseconds(),
+		})
+		if traced {
+			fields["trace_id"] = trace
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+			log.Event(ctx, "BreakfastRuined", fields)
+		} else {
+			log.Event(ctx, "BreakfastServed", fields)
+		}
+		return
+	}
+
+	if traced {
+		trace = " trace_id=" + trace
+	}
+	if err != nil {
+		log.Errorf("Breakfast is ruined! %s pancakes=%d duration=%s%s", err, pancakes, took, trace)
+	} else {
+		log.Infof("Breakfast Success! pancakes=%d duration=%s%s", pancakes, took, trace)
+	}
+}
+
+// eventFields adds what ties an event to its order and its trace to fields:
+// the order ID and the trace ID from ctx.
+func eventFields(ctx context.Context, fields logging.LoggableMap) logging.LoggableMap {
+	if id, ok := OrderIDFromContext(ctx); ok {
+		fields[orderIDTag] = id
+	}
+	if id, ok := traceID(ctx); ok {
+		fields["trace_id"] = id
+	}
+	return fields
+}
+
+// traceID returns the Jaeger trace ID of the span in ctx, or else of the
+// order being served. Other tracers' IDs aren't known.
+func traceID(ctx context.Context) (string, bool) {
+	var sc opentracing.SpanContext
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		sc = span.Context()
+	} else if order, ok := orderFromContext(ctx); ok {
+		sc = order
+	}
+	return spanTraceID(sc)
+}
+
+// spanTraceID returns the trace ID of sc, if it is a Jaeger span's.
+func spanTraceID(sc opentracing.SpanContext) (string, bool) {
+	if jsc, ok := sc.(jaeger.SpanContext); ok {
+		return jsc.TraceID().String(), true
+	}
+	return "", false
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	lwriter "github.com/ipfs/go-log/writer"
+	opentracing "github.com/opentracing/opentracing-go"
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+// useJaegerTracer traces with a Jaeger tracer, which has the trace IDs the
+// logs carry, for the rest of the test.
+func useJaegerTracer(t *testing.T) *jaeger.InMemoryReporter {
+	t.Helper()
+	reporter := jaeger.NewInMemoryReporter()
+	tracer, closer := jaeger.NewTracer("breakfast-test", jaeger.NewConstSampler(true), reporter)
+	prev := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	t.Cleanup(func() {
+		opentracing.SetGlobalTracer(prev)
+		closer.Close()
+	})
+	return reporter
+}
+
+func TestEventFieldsCarryTraceID(t *testing.T) {
+	useJaegerTracer(t)
+	span := StartRootSpan("ServeHotCakes")
+	defer span.Finish()
+
+	fields := eventFields(opentracing.ContextWithSpan(context.Background(), span), logging.LoggableMap{})
+	if want := span.Context().(jaeger.SpanContext).TraceID().String(); fields["trace_id"] != want {
+		t.Errorf("event trace_id = %v, want %s", fields["trace_id"], want)
+	}
+}
+
+func TestSummaryCarriesTraceID(t *testing.T) {
+	reporter := useJaegerTracer(t)
+
+	// Like runLoop, there is no span in the context to take the ID from
+	summary, err := ServeBreakfastWithSummary(context.Background(), WithDryRun(), WithCoffee(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := reporter.GetSpans()
+	if len(spans) == 0 {
+		t.Fatal("no spans reported")
+	}
+	if want := spans[0].Context().(jaeger.SpanContext).TraceID().String(); summary.TraceID != want {
+		t.Errorf("summary trace ID = %q, want the breakfast's %s", summary.TraceID, want)
+	}
+}
+
+// jsonEvents logs breakfasts as JSON for the rest of the test, sending each
+// event logged on the returned channel.
+func jsonEvents(t *testing.T) <-chan map[string]interface{} {
+	t.Helper()
+	jsonLogs = true
+	pr, pw := io.Pipe()
+	lwriter.WriterGroup.AddWriter(pw)
+	// Closing the reader fails the next write, which drops the writer
+	t.Cleanup(func() {
+		jsonLogs = false
+		pr.Close()
+	})
+
+	events := make(chan map[string]interface{}, 16)
+	go func() {
+		dec := json.NewDecoder(pr)
+		for {
+			var event map[string]interface{}
+			if err := dec.Decode(&event); err != nil {
+				return
+			}
+			events <- event
+		}
+	}()
+	return events
+}
+
+// nextEvent waits for the named event to be logged.
+func nextEvent(t *testing.T, events <-chan map[string]interface{}, name string) map[string]interface{} {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event["event"] == name {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("%s was never logged", name)
+		}
+	}
+}
+
+func TestLogBreakfast(t *testing.T) {
+	events := jsonEvents(t)
+
+	logBreakfast(context.Background(), nil, 3, BreakfastSummary{Duration: 20 * time.Millisecond, TraceID: "abc"})
+	served := nextEvent(t, events, "BreakfastServed")
+	if served["pancakes"] != float64(3) {
+		t.Errorf("BreakfastServed pancakes = %v, want 3", served["pancakes"])
+	}
+	if served["duration_ms"] != float64(20) {
+		t.Errorf("BreakfastServed duration_ms = %v, want 20", served["duration_ms"])
+	}
+	if served["trace_id"] != "abc" {
+		t.Errorf("BreakfastServed trace_id = %v, want the summary's abc", served["trace_id"])
+	}
+
+	logBreakfast(context.Background(), errors.New("dropped on the floor"), 3, BreakfastSummary{})
+	ruined := nextEvent(t, events, "BreakfastRuined")
+	if ruined["error"] != "dropped on the floor" {
+		t.Errorf("BreakfastRuined error = %v, want dropped on the floor", ruined["error"])
+	}
+	if _, ok := ruined["trace_id"]; ok {
+		t.Errorf("BreakfastRuined has trace_id %v without a trace", ruined["trace_id"])
+	}
+}
@@ -2,13 +2,25 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	logging "github.com/ipfs/go-log"
 	opentracing "github.com/opentracing/opentracing-go"
+	ext "github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+	jaeger "github.com/uber/jaeger-client-go"
 	config "github.com/uber/jaeger-client-go/config"
+	transport "github.com/uber/jaeger-client-go/transport"
 
 	breakfast "github.com/frrist/breakfast"
 )
@@ -16,132 +28,653 @@ import (
 var log = logging.Logger("breakfast")
 
 func main() {
-	fmt.Printf("Starting Jaeger...\n")
+	setupLogging()
 
-	tracer, err := InitTracer()
+	if addr := os.Getenv("BREAKFAST_HEALTH_ADDR"); addr != "" {
+		srv, err := StartHealthServer(addr)
+		if err != nil {
+			log.Errorf("Couldn't start health server: %s", err)
+			return
+		}
+		defer srv.Close()
+	}
+
+	// Jaeger by default, or OpenTelemetry when BREAKFAST_TRACER=otel
+	backend, initTracer := "Jaeger", InitTracer
+	if os.Getenv("BREAKFAST_TRACER") == "otel" {
+		backend, initTracer = "OpenTelemetry", InitOTelTracer
+	}
+	log.Infof("Starting %s...", backend)
+
+	// The supervisor replaces the tracer if it loses the backend for good
+	tracer := NewTracerSupervisor(func() (opentracing.Tracer, io.Closer, error) {
+		return initWithRetry(context.Background(), tracerInitAttempts, tracerInitDelay, initTracer)
+	}, tracerFailing)
+	err := tracer.Start()
 	if err != nil {
-		fmt.Printf("Couldn't init Jaeger Tracer: %s\n", err)
+		log.Errorf("Couldn't init %s Tracer: %s", backend, err)
 		return
 	}
+	// Flush any buffered spans to Jaeger before we exit
+	defer tracer.Close()
 	opentracing.SetGlobalTracer(tracer)
+	tracerReady.Store(true)
+
+	// Breakfasts served on a timer and ordered over HTTP share the kitchen,
+	// so shutting it down waits for both
+	kitchen := NewKitchen(1)
+	var orders *http.Server
+	if addr := os.Getenv("BREAKFAST_ORDER_ADDR"); addr != "" {
+		if orders, err = StartOrderServer(addr, kitchen); err != nil {
+			log.Errorf("Couldn't start order server: %s", err)
+			return
+		}
+		defer orders.Close()
+	}
+
+	interval := defaultInterval
+	if env := os.Getenv("BREAKFAST_INTERVAL"); env != "" {
+		if interval, err = time.ParseDuration(env); err != nil || interval <= 0 {
+			log.Warningf("Ignoring BREAKFAST_INTERVAL %q, serving every %s", env, defaultInterval)
+			interval = defaultInterval
+		}
+	}
+
+	// Serve until we are asked to shut down
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go tracer.Run(ctx, tracerCheckInterval)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		log.Info("Making Breakfast...")
+		runLoop(ctx, kitchen, interval)
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-sigs:
+	case <-stopped:
+	}
+
+	// Stop taking orders, but let the breakfast on the go finish so its
+	// trace is complete before the tracer is flushed
+	log.Info("Closing the kitchen...")
+	stop()
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if orders != nil {
+		// Answer the orders being cooked before the kitchen closes
+		if err := orders.Shutdown(sctx); err != nil {
+			log.Warningf("Gave up waiting for the last orders: %s", err)
+		}
+	}
+	if err := kitchen.Shutdown(sctx); err != nil {
+		log.Warningf("Gave up waiting for the last breakfast: %s", err)
+	}
+}
+
+// How many times main tries to initialize the tracer, how long it first
+// waits between tries, and how often it checks the tracer is still reporting
+const (
+	tracerInitAttempts  = 5
+	tracerInitDelay     = 500 * time.Millisecond
+	tracerCheckInterval = 10 * time.Second
+)
 
-	fmt.Printf("Making Breakfast...\n")
+// How often main serves breakfast unless BREAKFAST_INTERVAL says otherwise
+const defaultInterval = 1 * time.Second
+
+// How long main waits for the last breakfast when shutting down
+const shutdownTimeout = 10 * time.Second
+
+// runLoop serves a breakfast in the kitchen straight away and then one each
+// interval, until ctx is cancelled or the kitchen closes. Cancelling ctx
+// doesn't interrupt a breakfast already being served. A breakfast that takes
+// longer than interval delays the next rather than piling up. It returns how
+// many breakfasts were served.
+func runLoop(ctx context.Context, k *Kitchen, interval time.Duration) int {
+	serving.Store(true)
+	defer serving.Store(false)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// However many the kitchen's options order, the default if they don't say
+	pancakes := newOptions(k.options(nil)).pancakes
+	served := 0
 	for {
-		if err := ServeBreakfast(); err != nil {
-			fmt.Printf("Breakfast is ruined! %s\n", err)
-		} else {
-			fmt.Printf("Breakfast Success!\n")
+		// ctx has no span, so the log gets its trace from the summary
+		summary, err := k.ServeWithSummary(context.WithoutCancel(ctx))
+		if err == ErrKitchenClosed {
+			return served
 		}
+		logBreakfast(ctx, err, pancakes, summary)
+		served++
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return served
+		}
+	}
+}
+
+// Baggage item carrying the table a breakfast is for
+const tableBaggageKey = "table_number"
+
+// StartRootSpan starts a span with the global tracer that is not below any
+// span in a context. opts can seed it with tags, like service.version, or
+// set its start time with opentracing.StartTime, e.g. for a replayed order.
+func StartRootSpan(name string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	return opentracing.GlobalTracer().StartSpan(name, opts...)
+}
+
+// StartFollowUpSpan starts a span for work that parent set off but doesn't
+// wait for. It follows from parent rather than being its child, so it may
+// outlive it. With a nil parent it starts a root span.
+func StartFollowUpSpan(parent opentracing.Span, name string) opentracing.Span {
+	if parent == nil {
+		return StartRootSpan(name)
+	}
+	return parent.Tracer().StartSpan(name, opentracing.FollowsFrom(parent.Context()))
+}
+
+// ForceSample asks the tracer to keep the trace of the span in ctx, whatever
+// the sampler would have decided.
+func ForceSample(ctx context.Context) {
+	ext.SamplingPriority.Set(MustSpan(ctx), 1)
+}
+
+// MustSpan returns the span in ctx, or a span that records nothing if there
+// isn't one, so the result can always be used.
+func MustSpan(ctx context.Context) opentracing.Span {
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		return span
 	}
+	return opentracing.NoopTracer{}.StartSpan("")
+}
+
+func ServeBreakfast(ctx context.Context, opts ...Option) error {
+	_, err := ServeBreakfastWithSummary(ctx, opts...)
+	return err
 }
 
-func ServeBreakfast() error {
+// ServeBreakfastWithSummary serves breakfast like ServeBreakfast, and also
+// returns what happened to its pancakes. The summary is filled in even when
+// the breakfast is ruined.
+func ServeBreakfastWithSummary(ctx context.Context, opts ...Option) (summary BreakfastSummary, err error) {
+	// Count what happens to this breakfast's pancakes for the summary below
+	t := &tally{}
+	opts = append(opts[:len(opts):len(opts)], withTally(t))
+	o := newOptions(opts)
+	start := o.clock.Now()
+
 	//Context used for the request
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	// Create a span called rootSpan.
 	// This span will be the parent of all other spans created
 	// during the exection of methods called inside ServeBreakfast
-	rootSpan := opentracing.StartSpan("ServeHotCakes")
+	spanOpts := o.spanOpts
+	if order, ok := orderFromContext(ctx); ok {
+		// Continue the trace of an order placed elsewhere
+		spanOpts = append(spanOpts[:len(spanOpts):len(spanOpts)], opentracing.ChildOf(order))
+	}
+	rootSpan := StartRootSpan("ServeHotCakes", spanOpts...)
 	defer rootSpan.Finish()
+	if o.table != "" {
+		rootSpan.SetBaggageItem(tableBaggageKey, o.table)
+	}
+	if id, ok := OrderIDFromContext(ctx); ok {
+		rootSpan.SetTag(orderIDTag, id)
+	}
+	if o.forceSample {
+		ext.SamplingPriority.Set(rootSpan, 1)
+	}
+
+	// Summarize the breakfast on the root span, before it is finished
+	defer func() {
+		took := o.clock.Now().Sub(start)
+		summary = t.summary(took)
+		summary.TraceID, _ = spanTraceID(rootSpan.Context())
+		syruped := t.syruped.Load()
+		rootSpan.SetTag("pancakes.count", o.pancakes)
+		rootSpan.SetTag("pancakes.burnt", t.burnt.Load())
+		rootSpan.SetTag("pancakes.syruped", syruped)
+		rootSpan.SetTag("calories", t.calories())
+		rootSpan.SetTag("duration_ms", took.Milliseconds())
+		if took > 0 {
+			rootSpan.SetTag("throughput_per_sec", float64(syruped)/took.Seconds())
+		}
+	}()
 
 	// Create a new ctx that holds a reference to rootSpan's SpanContext
 	ctx = opentracing.ContextWithSpan(ctx, rootSpan)
 
-	//Lets make some pancakes
-	cakes := breakfast.MakePancakes(3)
+	// Get the coffee going while we cook
+	var coffee <-chan Coffee
+	if o.coffee > 0 {
+		if coffee, err = BrewCoffee(ctx, o.coffee, opts...); err != nil {
+			return summary, err
+		}
+	}
 
-	// If an error occurs, tag the span and log the error
-	if err := FlipPancakes(ctx, cakes); err != nil {
-		return err
+	meal := &Breakfast{
+		Items: []Recipe{Pancakes{Count: o.pancakes, Options: opts}},
+	}
+	if err := meal.Serve(ctx); err != nil {
+		return summary, err
 	}
-	ready := SyrupPancakes(ctx, cakes)
-	EatPancakes(ready)
-	return nil
-}
-func FlipPancakes(ctx context.Context, cakes []breakfast.Pancake) (err error) {
-	// Create an EventInProgress - eip - named FlipPancakes
-	eip := log.EventBegin(ctx, "FlipPancakes")
-	defer func() {
-		if err != nil {
-			eip.SetError(err)
-		}
-		eip.Done()
-	}()
 
-	for p := range cakes {
-		if err := cakes[p].Flip(); err != nil {
-			return err
-		}
+	// Pour whatever coffee is ready, waiting for the rest
+	cups := 0
+	for range coffee {
+		cups++
 	}
+	rootSpan.SetTag("coffee.cups", cups)
+	return summary, nil
+}
 
-	// Let the pancakes cook
-	time.Sleep(1 * time.Second)
+// ServeBreakfastWithTimeout serves breakfast, giving up if it isn't ready
+// within d. A late breakfast fails with an error wrapping
+// context.DeadlineExceeded.
+func ServeBreakfastWithTimeout(d time.Duration, opts ...Option) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return ServeBreakfast(ctx, opts...)
+}
 
+func FlipPancakes(ctx context.Context, cakes []breakfast.Pancake, opts ...Option) error {
+	items := make([]*breakfast.Pancake, len(cakes))
 	for p := range cakes {
-		if cakes[p].IsBurnt() {
-			return errors.New("Burnt Pancake")
-		}
+		items[p] = &cakes[p]
 	}
-
-	return nil
+	return flipAll(ctx, items, contextOptions(ctx, opts), pancakeFlips)
 }
-func SyrupPancakes(ctx context.Context, cakes []breakfast.Pancake) <-chan breakfast.Pancake {
-	// Create a new ctx that holds a reference to a log event in progress
-	ctx = log.EventBeginInContext(ctx, "PancakeReady")
+func SyrupPancakes(ctx context.Context, cakes []breakfast.Pancake, opts ...Option) <-chan breakfast.Pancake {
+	return syrupPancakes(ctx, cakes, contextOptions(ctx, opts), nil)
+}
+
+// syrupPancakes is SyrupPancakes, also storing in failed why the syruping
+// gave up, if it did, by the time the channel is closed. Soggy pancakes left
+// over once it has finished aren't a failure, just mistakes.
+func syrupPancakes(ctx context.Context, cakes []breakfast.Pancake, o *options, failed *error) <-chan breakfast.Pancake {
+	// Create an EventInProgress that stays open until every pancake is handled
+	eip := log.EventBegin(ctx, "PancakeReady", eventFields(ctx, logging.LoggableMap{"syrup": o.syrup}))
 	// The channel perfectly syruped pancakes will be written to
-	out := make(chan breakfast.Pancake)
+	out := make(chan breakfast.Pancake, o.buffer)
 	go func() {
-		// If there is an event in the context, defer compltion of it
-		// until we have handled all pancakes.
-		defer logging.MaybeFinishEvent(ctx)
-		defer close(out)
-
 		// Where soggy pancakes go..
 		var mistakes []breakfast.Pancake
+
+		// Give up on the syruping, failing the event with err
+		fail := func(err error) {
+			if failed != nil {
+				*failed = err
+			}
+			eip.SetError(err)
+		}
+		sender := &pancakeSender{ctx: ctx, o: o, out: out, fail: fail}
+
+		// However we stop, close the channel and finish the event with a
+		// count of the pancakes that made it out.
+		defer func() {
+			if o.mistakes != nil {
+				*o.mistakes = mistakes
+			}
+			o.leftSoggy(len(mistakes))
+			close(out)
+			sender.done(eip)
+			eip.Done()
+		}()
+
 		for p := range cakes {
-			if err := cakes[p].Syrup(); err != nil {
-				fmt.Errorf("Ohh no, soggy pancakes!")
+			if err := syrupPancake(ctx, &cakes[p], p, o); err != nil {
+				log.Warning(fmt.Errorf("pancake %d: %w", p, ErrSoggyPancake))
+				o.soggy()
 				mistakes = append(mistakes, cakes[p])
+				if o.maxMistakes > 0 && len(mistakes) > o.maxMistakes {
+					// This batch is a lost cause
+					fail(fmt.Errorf("%w: %d soggy pancakes", ErrTooManyMistakes, len(mistakes)))
+					return
+				}
 				continue
 			}
-			select {
-			// Send off our perfect pancakes
-			case out <- cakes[p]:
-			case <-ctx.Done():
+			if !sender.send(cakes[p]) {
 				return
 			}
-			if len(mistakes) == 0 {
-				return
-			} else {
-				// fix your pancakes...
+		}
+		if len(mistakes) > 0 && o.syrupRetries > 0 {
+			// fix your pancakes...
+			var fixed []breakfast.Pancake
+			fixed, mistakes = fixSoggyPancakes(ctx, mistakes, o.syrupRetries, o)
+			for _, cake := range fixed {
+				if !sender.send(cake) {
+					return
+				}
 			}
 		}
+		if len(mistakes) > 0 {
+			eip.SetError(fmt.Errorf("%d pancakes: %w", len(mistakes), ErrSoggyPancake))
+		}
 	}()
 
 	return out
 }
 
-func EatPancakes(<-chan breakfast.Pancake) {
-	return
+// pancakeSender sends syruped pancakes on out for SyrupPancakes and
+// SyrupPancakesConcurrent, so both stop the same way: when ctx is cancelled,
+// when nobody takes a pancake within the write timeout, or once stop is
+// closed. Several workers may send at once.
+type pancakeSender struct {
+	ctx  context.Context
+	o    *options
+	out  chan<- breakfast.Pancake
+	stop <-chan struct{}
+	// Called when sending gives up because something went wrong
+	fail func(error)
+
+	syruped atomic.Int64
+	// Pancakes in hand when sending stopped, syruped but never delivered or
+	// no longer wanted by a customer who left
+	lost, wasted    atomic.Int64
+	cancelled, left atomic.Bool
+}
+
+// send sends cake, returning false if it stopped first.
+func (s *pancakeSender) send(cake breakfast.Pancake) bool {
+	// Someone may still be taking pancakes after cancelling, e.g. to drain
+	// the channel, so don't offer them any more
+	if s.ctx.Err() != nil {
+		return s.cancel(1)
+	}
+	// Nobody taking the pancake within the write timeout means nobody is
+	// eating, so stop rather than wait forever
+	var timeout <-chan time.Time
+	if s.o.writeTimeout > 0 {
+		timeout = s.o.clock.After(s.o.writeTimeout)
+	}
+	select {
+	case s.out <- cake:
+		s.syruped.Add(1)
+		s.o.syruped()
+		return true
+	case <-s.ctx.Done():
+		return s.cancel(1)
+	case <-s.stop:
+		return false
+	case <-timeout:
+		s.lost.Add(1)
+		s.fail(fmt.Errorf("%w after %s", ErrNoConsumer, s.o.writeTimeout))
+		return false
+	}
+}
+
+// cancel stops sending because ctx was cancelled, with inHand pancakes left
+// to send. It always returns false.
+func (s *pancakeSender) cancel(inHand int64) bool {
+	if context.Cause(s.ctx) == ErrCustomerLeft {
+		// Nobody wants the pancakes in hand, but nothing went wrong
+		s.wasted.Add(inHand)
+		s.left.Store(true)
+		return false
+	}
+	// The pancakes in hand were syruped but never delivered, count them so
+	// the kitchen's numbers still add up
+	s.lost.Add(inHand)
+	s.cancelled.Store(true)
+	s.fail(s.ctx.Err())
+	return false
+}
+
+// done notes on eip how many pancakes were sent, and what became of those
+// left in hand if sending stopped.
+func (s *pancakeSender) done(eip *logging.EventInProgress) {
+	fields := logging.LoggableMap{"syruped": s.syruped.Load()}
+	if s.left.Load() {
+		fields["customer_left"] = true
+		fields["wasted"] = s.wasted.Load()
+	}
+	if s.cancelled.Load() {
+		fields["cancelled"] = true
+	}
+	if n := s.lost.Load(); n > 0 {
+		fields["lost_inflight"] = n
+	}
+	eip.Append(fields)
+}
+
+// syrupPancake syrups a single pancake under its own span, tagged with
+// whether it went soggy and how long the syrup took to soak in.
+func syrupPancake(ctx context.Context, cake *breakfast.Pancake, index int, o *options) error {
+	span, _ := startSpan(ctx, "SyrupPancake")
+	defer span.Finish()
+	span.SetTag("pancake.index", index)
+
+	start := o.clock.Now()
+	err := o.syrupPancake(cake)
+	took := o.clock.Now().Sub(start)
+	span.SetTag("absorption_ms", float64(took.Microseconds())/1000)
+	o.metrics.observe("syrup", took)
+	span.SetTag("soggy", err != nil)
+	if err != nil {
+		span.LogFields(otlog.Error(err))
+	}
+	return err
+}
+
+// FixSoggyPancakes syrups the soggy pancakes again, up to tries times each,
+// and returns the ones it fixed along with the ones still soggy. opts apply
+// to the syruping as they do in SyrupPancakes.
+func FixSoggyPancakes(ctx context.Context, mistakes []breakfast.Pancake, tries int, opts ...Option) (fixed, soggy []breakfast.Pancake) {
+	return fixSoggyPancakes(ctx, mistakes, tries, contextOptions(ctx, opts))
+}
+
+func fixSoggyPancakes(ctx context.Context, mistakes []breakfast.Pancake, tries int, o *options) (fixed, soggy []breakfast.Pancake) {
+	span, _ := startSpan(ctx, "FixSoggyPancakes")
+	defer span.Finish()
+
+	for try := 0; try < tries && len(mistakes) > 0; try++ {
+		soggy = nil
+		for p := range mistakes {
+			if err := o.syrupPancake(&mistakes[p]); err != nil {
+				soggy = append(soggy, mistakes[p])
+				continue
+			}
+			fixed = append(fixed, mistakes[p])
+		}
+		mistakes = soggy
+	}
+	span.SetTag("pancakes.recovered", len(fixed))
+	span.SetTag("pancakes.soggy", len(mistakes))
+	return fixed, mistakes
+}
+
+// EatPancakes eats every pancake sent on the channel until it is closed, and
+// returns how many were eaten.
+func EatPancakes(ctx context.Context, ready <-chan breakfast.Pancake) int {
+	return eatPancakes(ctx, ready, newOptions(nil), nil)
+}
+
+// eatPancakes eats like EatPancakes, except that with a positive appetite it
+// stops after that many pancakes. It then calls leave so whoever is making
+// them stops too, and waits for ready to close.
+func eatPancakes(ctx context.Context, ready <-chan breakfast.Pancake, o *options, leave func()) int {
+	appetite := o.appetite
+	span, _ := startSpan(ctx, "EatPancakes")
+	defer span.Finish()
+
+	// Time the gaps between pancakes to see how fast they're eaten
+	var plate Plate
+	start := o.clock.Now()
+	last := start
+	for p := range ready {
+		if o.dryRun {
+			plate.AddDry(p)
+		} else {
+			plate.Add(p)
+		}
+		now := o.clock.Now()
+		span.LogFields(
+			otlog.String("event", "eaten"),
+			otlog.Int("pancake.index", plate.Len()-1),
+			otlog.String("waited", now.Sub(last).String()),
+		)
+		o.metrics.observe("eat", now.Sub(last))
+		last = now
+
+		if appetite > 0 && plate.Len() >= appetite {
+			span.SetTag("appetite", appetite)
+			span.LogFields(otlog.String("event", "left"))
+			leave()
+			// Wait for the syruping to stop, anything still coming is wasted
+			for range ready {
+			}
+			break
+		}
+	}
+	if n := plate.Len(); n > 0 {
+		span.SetTag("eat_interval_ms", last.Sub(start).Milliseconds()/int64(n))
+	}
+	span.SetTag("plate.pancakes", plate.Len())
+	span.SetTag("plate.calories", plate.Calories())
+
+	eaten := 0
+	if cakes, err := plate.Serve(); err != nil {
+		span.LogFields(otlog.Error(err))
+	} else {
+		eaten = len(cakes)
+	}
+	span.SetTag("pancakes.eaten", eaten)
+	if table := span.BaggageItem(tableBaggageKey); table != "" {
+		span.SetTag(tableBaggageKey, table)
+		log.Infof("table %s ate %d pancakes", table, eaten)
+	}
+	return eaten
+}
+
+// Default port of the Jaeger agent's compact thrift endpoint
+const defaultAgentPort = "6831"
+
+// reporterConfig says where spans are reported. JAEGER_ENDPOINT sends them
+// straight to a collector; otherwise they go to the agent at
+// JAEGER_AGENT_HOST and JAEGER_AGENT_PORT. Unset, they go to an agent on
+// localhost.
+func reporterConfig() *config.ReporterConfig {
+	cfg := &config.ReporterConfig{}
+	if endpoint := os.Getenv("JAEGER_ENDPOINT"); endpoint != "" {
+		cfg.CollectorEndpoint = endpoint
+		return cfg
+	}
+	host, port := os.Getenv("JAEGER_AGENT_HOST"), os.Getenv("JAEGER_AGENT_PORT")
+	if host != "" || port != "" {
+		if host == "" {
+			host = "localhost"
+		}
+		if port == "" {
+			port = defaultAgentPort
+		}
+		cfg.LocalAgentHostPort = net.JoinHostPort(host, port)
+	}
+	return cfg
+}
+
+// Sampler types understood by InitTracerWithSampling
+var samplerTypes = []string{"const", "probabilistic", "ratelimiting"}
+
+// Initalize a Jaeger tracer with constant sampling. The returned io.Closer
+// must be closed before exiting so buffered spans are flushed to Jaeger.
+func InitTracer() (opentracing.Tracer, io.Closer, error) {
+	return InitTracerWithSampling("const", 1)
+}
+
+// InitTracerWithRetry initializes a Jaeger tracer like InitTracer, trying up
+// to attempts times in case the backend isn't up yet. The delay between tries
+// starts at baseDelay and doubles each time, with some jitter. If every
+// attempt fails the last error is returned.
+func InitTracerWithRetry(ctx context.Context, attempts int, baseDelay time.Duration) (opentracing.Tracer, io.Closer, error) {
+	return initWithRetry(ctx, attempts, baseDelay, InitTracer)
+}
+
+func initWithRetry(ctx context.Context, attempts int, baseDelay time.Duration, init func() (opentracing.Tracer, io.Closer, error)) (opentracing.Tracer, io.Closer, error) {
+	var err error
+	delay := baseDelay
+	for a := 1; ; a++ {
+		var tracer opentracing.Tracer
+		var closer io.Closer
+		if tracer, closer, err = init(); err == nil {
+			return tracer, closer, nil
+		}
+		if a >= attempts {
+			return nil, nil, err
+		}
+
+		// Jitter keeps a fleet of kitchens from retrying in lockstep
+		wait := delay
+		if delay > 0 {
+			wait += time.Duration(rand.Int63n(int64(delay)))
+		}
+		log.Warningf("Tracer init attempt %d failed, retrying in %s: %s", a, wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, nil, err
+		}
+		delay *= 2
+	}
 }
 
-//Initalize a Jaeger tracer with constant sampling
-func InitTracer() (opentracing.Tracer, error) {
+// InitTracerWithSampling initializes a Jaeger tracer using the given sampler.
+// param means whatever Jaeger takes it to mean for samplerType: 0 or 1 for
+// const, a probability for probabilistic and traces per second for
+// ratelimiting.
+func InitTracerWithSampling(samplerType string, param float64) (opentracing.Tracer, io.Closer, error) {
+	known := false
+	for _, t := range samplerTypes {
+		if t == samplerType {
+			known = true
+		}
+	}
+	if !known {
+		return nil, nil, fmt.Errorf("unknown sampler type %q, expected one of %s", samplerType, strings.Join(samplerTypes, ", "))
+	}
+
+	reporter, err := newReporter()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	tracerCfg := &config.Configuration{
 		Sampler: &config.SamplerConfig{
-			Type:  "const",
-			Param: 1,
-		},
-		Reporter: &config.ReporterConfig{
-			LogSpans: true,
+			Type:  samplerType,
+			Param: param,
 		},
 	}
-	//we are ignoring the closer for now
-	tracer, _, err := tracerCfg.New("Breakfast")
+	tracer, closer, err := tracerCfg.New("Breakfast", config.Reporter(reporter))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	return tracer, closer, nil
+}
+
+// How many reports in a row may fail before the breaker trips, and how long
+// it then stays open
+const (
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+// newTransport connects to the collector or agent rc points at.
+func newTransport(rc *config.ReporterConfig) (jaeger.Transport, error) {
+	if rc.CollectorEndpoint != "" {
+		return transport.NewHTTPTransport(rc.CollectorEndpoint), nil
+	}
+	hostPort := rc.LocalAgentHostPort
+	if hostPort == "" {
+		hostPort = net.JoinHostPort("localhost", defaultAgentPort)
 	}
-	return tracer, nil
+	return jaeger.NewUDPTransport(hostPort, 0)
 }
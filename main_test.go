@@ -0,0 +1,789 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	mocktracer "github.com/opentracing/opentracing-go/mocktracer"
+	jaeger "github.com/uber/jaeger-client-go"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+// useMockTracer records spans and events in a mock tracer for the rest of
+// the test.
+func useMockTracer(t *testing.T) *mocktracer.MockTracer {
+	t.Helper()
+	prev := opentracing.GlobalTracer()
+	mt := mocktracer.New()
+	opentracing.SetGlobalTracer(mt)
+	t.Cleanup(func() { opentracing.SetGlobalTracer(prev) })
+	return mt
+}
+
+// eventField returns what the finished event was logged with under key.
+func eventField(mt *mocktracer.MockTracer, event, key string) (string, bool) {
+	for _, span := range mt.FinishedSpans() {
+		if span.OperationName != event {
+			continue
+		}
+		for _, rec := range span.Logs() {
+			for _, f := range rec.Fields {
+				if f.Key == key {
+					return f.ValueString, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// waitForEvent waits for the event to finish, failing the test if it takes
+// more than a second.
+func waitForEvent(t *testing.T, mt *mocktracer.MockTracer, event string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, span := range mt.FinishedSpans() {
+			if span.OperationName == event {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%s never finished", event)
+}
+
+// soggyAt has the nth pancake syruped go soggy, for each n in calls,
+// counting from 0.
+func soggyAt(calls ...int) Option {
+	soggy := make(map[int]bool, len(calls))
+	for _, n := range calls {
+		soggy[n] = true
+	}
+	var poured atomic.Int64
+	return func(o *options) {
+		o.pour = func(*breakfast.Pancake) error {
+			if soggy[int(poured.Add(1)-1)] {
+				return ErrSoggyPancake
+			}
+			return nil
+		}
+	}
+}
+
+// cleanFlips has every flip succeed.
+func cleanFlips() Option {
+	return func(o *options) {
+		o.toss = func(Cookable) error { return nil }
+	}
+}
+
+func collect(ready <-chan breakfast.Pancake) []breakfast.Pancake {
+	var cakes []breakfast.Pancake
+	for cake := range ready {
+		cakes = append(cakes, cake)
+	}
+	return cakes
+}
+
+func TestSyrupPancakesSendsEveryPancake(t *testing.T) {
+	mt := useMockTracer(t)
+
+	got := collect(SyrupPancakes(context.Background(), breakfast.MakePancakes(5), WithDryRun()))
+	if len(got) != 5 {
+		t.Fatalf("got %d pancakes, want 5", len(got))
+	}
+	if syruped, _ := eventField(mt, "PancakeReady", "syruped"); syruped != "5" {
+		t.Errorf("PancakeReady syruped = %q, want 5", syruped)
+	}
+}
+
+func TestSyrupPancakesHoldsBackSoggyPancakes(t *testing.T) {
+	mt := useMockTracer(t)
+
+	var mistakes []breakfast.Pancake
+	got := collect(SyrupPancakes(context.Background(), breakfast.MakePancakes(5), soggyAt(1, 3), WithMistakes(&mistakes)))
+	if len(got) != 3 {
+		t.Errorf("got %d pancakes, want the 3 good ones", len(got))
+	}
+	if len(mistakes) != 2 {
+		t.Errorf("got %d mistakes, want 2", len(mistakes))
+	}
+	if syruped, _ := eventField(mt, "PancakeReady", "syruped"); syruped != "3" {
+		t.Errorf("PancakeReady syruped = %q, want 3", syruped)
+	}
+}
+
+func TestSyrupPancakesStopsWhenCancelled(t *testing.T) {
+	mt := useMockTracer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready := SyrupPancakes(ctx, breakfast.MakePancakes(5), WithDryRun())
+	<-ready
+	<-ready
+	cancel()
+
+	waitForEvent(t, mt, "PancakeReady")
+	if _, ok := <-ready; ok {
+		t.Error("got a pancake after cancelling, want the channel closed")
+	}
+	if syruped, _ := eventField(mt, "PancakeReady", "syruped"); syruped != "2" {
+		t.Errorf("PancakeReady syruped = %q, want 2", syruped)
+	}
+	if cancelled, _ := eventField(mt, "PancakeReady", "cancelled"); cancelled != "true" {
+		t.Errorf("PancakeReady cancelled = %q, want true", cancelled)
+	}
+}
+
+func TestSyrupPancakesCountsPancakeLostOnCancel(t *testing.T) {
+	mt := useMockTracer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Nobody reads, so the first pancake is stuck being sent
+	ready := SyrupPancakes(ctx, breakfast.MakePancakes(3), WithDryRun())
+	cancel()
+
+	waitForEvent(t, mt, "PancakeReady")
+	if _, ok := <-ready; ok {
+		t.Error("got a pancake after cancelling, want the channel closed")
+	}
+	if lost, _ := eventField(mt, "PancakeReady", "lost_inflight"); lost != "1" {
+		t.Errorf("PancakeReady lost_inflight = %q, want 1", lost)
+	}
+	if syruped, _ := eventField(mt, "PancakeReady", "syruped"); syruped != "0" {
+		t.Errorf("PancakeReady syruped = %q, want 0", syruped)
+	}
+}
+
+func TestSyrupPancakesGivesUpAfterMaxMistakes(t *testing.T) {
+	mt := useMockTracer(t)
+
+	var mistakes []breakfast.Pancake
+	got := collect(SyrupPancakes(context.Background(), breakfast.MakePancakes(5), soggyAt(0, 1, 2), WithMaxMistakes(2), WithMistakes(&mistakes)))
+	if len(got) != 0 {
+		t.Errorf("got %d pancakes, want none once the batch was given up on", len(got))
+	}
+	if len(mistakes) != 3 {
+		t.Errorf("got %d mistakes, want 3", len(mistakes))
+	}
+	if errField, _ := eventField(mt, "PancakeReady", "error"); !strings.Contains(errField, ErrTooManyMistakes.Error()) {
+		t.Errorf("PancakeReady error = %q, want %q", errField, ErrTooManyMistakes)
+	}
+}
+
+func TestEatPancakesTracesEating(t *testing.T) {
+	mt := useMockTracer(t)
+	ready := make(chan breakfast.Pancake, 3)
+	for _, cake := range breakfast.MakePancakes(3) {
+		ready <- cake
+	}
+	close(ready)
+
+	if eaten := EatPancakes(context.Background(), ready); eaten != 3 {
+		t.Errorf("ate %d pancakes, want 3", eaten)
+	}
+	if got := breakfasttest.SpanTag(mt, "EatPancakes", "pancakes.eaten"); got != 3 {
+		t.Errorf("EatPancakes pancakes.eaten = %v, want 3", got)
+	}
+}
+
+func TestSyrupPancakesGivesUpWithNoReader(t *testing.T) {
+	mt := useMockTracer(t)
+
+	// Nobody ever reads
+	ready := SyrupPancakes(context.Background(), breakfast.MakePancakes(3), WithDryRun(), WithWriteTimeout(10*time.Millisecond))
+
+	waitForEvent(t, mt, "PancakeReady")
+	if errField, _ := eventField(mt, "PancakeReady", "error"); !strings.Contains(errField, ErrNoConsumer.Error()) {
+		t.Errorf("PancakeReady error = %q, want %q", errField, ErrNoConsumer)
+	}
+	if lost, _ := eventField(mt, "PancakeReady", "lost_inflight"); lost != "1" {
+		t.Errorf("PancakeReady lost_inflight = %q, want 1", lost)
+	}
+	if _, ok := <-ready; ok {
+		t.Error("got a pancake after giving up, want the channel closed")
+	}
+}
+
+func TestMustSpan(t *testing.T) {
+	// Without a span there is still one to use, which records nothing
+	span := MustSpan(context.Background())
+	if span == nil {
+		t.Fatal("MustSpan returned nil without a span in the context")
+	}
+	span.SetTag("heat", "medium")
+	span.Finish()
+
+	mt := useMockTracer(t)
+	want := mt.StartSpan("FlipPancakes")
+	if got := MustSpan(opentracing.ContextWithSpan(context.Background(), want)); got != want {
+		t.Errorf("got %v, want the span in the context", got)
+	}
+}
+
+func TestStartRootSpanAppliesOptions(t *testing.T) {
+	mt := useMockTracer(t)
+
+	// A replayed order started an hour ago
+	began := time.Now().Add(-time.Hour).Round(time.Millisecond)
+	span := StartRootSpan("ServeHotCakes",
+		opentracing.Tags{"service.version": "1.2.3", "table": 7},
+		opentracing.StartTime(began),
+	)
+	span.Finish()
+
+	got := breakfasttest.AssertSpanExists(t, mt, "ServeHotCakes")
+	if got.ParentID != 0 {
+		t.Errorf("root span has parent %d, want none", got.ParentID)
+	}
+	if !got.StartTime.Equal(began) {
+		t.Errorf("root span started at %v, want %v", got.StartTime, began)
+	}
+	if v := got.Tag("service.version"); v != "1.2.3" {
+		t.Errorf("service.version = %v, want 1.2.3", v)
+	}
+	if v := got.Tag("table"); v != 7 {
+		t.Errorf("table = %v, want 7", v)
+	}
+}
+
+func TestStartFollowUpSpanFollowsFromParent(t *testing.T) {
+	reporter := useJaegerTracer(t)
+
+	parent := StartRootSpan("ServeHotCakes")
+	follow := StartFollowUpSpan(parent, "MergePancakes")
+	orphan := StartFollowUpSpan(nil, "MergePancakes")
+	// The follow-up may outlive the span that set it off
+	parent.Finish()
+	follow.Finish()
+	orphan.Finish()
+
+	spans := reporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans reported, want 3", len(spans))
+	}
+	parentCtx := parent.Context().(jaeger.SpanContext)
+	refs := spans[1].(*jaeger.Span).References()
+	if len(refs) != 1 {
+		t.Fatalf("follow-up has %d references, want 1", len(refs))
+	}
+	if refs[0].Type != opentracing.FollowsFromRef {
+		t.Errorf("follow-up reference is %v, want FollowsFrom", refs[0].Type)
+	}
+	if got := refs[0].ReferencedContext.(jaeger.SpanContext); got.SpanID() != parentCtx.SpanID() {
+		t.Errorf("follow-up follows from span %s, want %s", got.SpanID(), parentCtx.SpanID())
+	}
+	if got := follow.Context().(jaeger.SpanContext); got.TraceID() != parentCtx.TraceID() {
+		t.Errorf("follow-up in trace %s, want its parent's %s", got.TraceID(), parentCtx.TraceID())
+	}
+
+	// Without a parent it starts a trace of its own
+	if refs := spans[2].(*jaeger.Span).References(); len(refs) != 0 {
+		t.Errorf("orphan follow-up has %d references, want none", len(refs))
+	}
+}
+
+func TestInitTracerClosesTwice(t *testing.T) {
+	// Print spans rather than look for an agent
+	t.Setenv("BREAKFAST_REPORTER", "stdout")
+	_, closer, err := InitTracer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatalf("closing the tracer: %v", err)
+	}
+	// A deferred Close after an explicit one must be harmless
+	if err := closer.Close(); err != nil {
+		t.Errorf("closing the tracer again: %v", err)
+	}
+}
+
+// failingInit fails its first fails calls, then hands back a mock tracer.
+func failingInit(fails int) (init func() (opentracing.Tracer, io.Closer, error), calls *int) {
+	calls = new(int)
+	return func() (opentracing.Tracer, io.Closer, error) {
+		*calls++
+		if *calls <= fails {
+			return nil, nil, fmt.Errorf("agent not up, attempt %d", *calls)
+		}
+		return mocktracer.New(), io.NopCloser(nil), nil
+	}, calls
+}
+
+func TestInitWithRetryRecovers(t *testing.T) {
+	init, calls := failingInit(2)
+	tracer, closer, err := initWithRetry(context.Background(), 5, time.Millisecond, init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracer == nil || closer == nil {
+		t.Errorf("got tracer %v and closer %v, want both", tracer, closer)
+	}
+	if *calls != 3 {
+		t.Errorf("init called %d times, want 3", *calls)
+	}
+}
+
+func TestInitWithRetryGivesUp(t *testing.T) {
+	init, calls := failingInit(10)
+	_, _, err := initWithRetry(context.Background(), 3, time.Millisecond, init)
+	if err == nil || !strings.Contains(err.Error(), "attempt 3") {
+		t.Errorf("got %v, want the last attempt's error", err)
+	}
+	if *calls != 3 {
+		t.Errorf("init called %d times, want 3", *calls)
+	}
+}
+
+func TestInitWithRetryStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	init, calls := failingInit(10)
+
+	// The retry delay is far longer than the test, so only the context can
+	// end it
+	_, _, err := initWithRetry(ctx, 5, time.Hour, init)
+	if err == nil || !strings.Contains(err.Error(), "attempt 1") {
+		t.Errorf("got %v, want the first attempt's error", err)
+	}
+	if *calls != 1 {
+		t.Errorf("init called %d times, want 1", *calls)
+	}
+}
+
+func TestFlipPancakesSpanPerPancake(t *testing.T) {
+	mt := useMockTracer(t)
+
+	if err := FlipPancakes(context.Background(), breakfast.MakePancakes(3), WithDryRun()); err != nil {
+		t.Fatal(err)
+	}
+	spans := breakfasttest.FindSpans(mt, "FlipPancake")
+	if len(spans) != 3 {
+		t.Fatalf("got %d FlipPancake spans, want one per pancake", len(spans))
+	}
+	seen := make(map[interface{}]bool)
+	for _, span := range spans {
+		seen[span.Tag("pancake.index")] = true
+		if state := span.Tag("pancake.state"); state != "cooked" {
+			t.Errorf("pancake %v state = %v, want cooked", span.Tag("pancake.index"), state)
+		}
+	}
+	for p := 0; p < 3; p++ {
+		if !seen[p] {
+			t.Errorf("no FlipPancake span for pancake %d", p)
+		}
+	}
+}
+
+func TestFlipPancakesSaysWhichPancakeBurnt(t *testing.T) {
+	useMockTracer(t)
+	cakes := breakfast.MakePancakes(4)
+	burner := BurnDetectorFunc(func(item Cookable, _ time.Duration) bool {
+		return item == Cookable(&cakes[2])
+	})
+
+	err := FlipPancakes(context.Background(), cakes, cleanFlips(), WithCookDuration(time.Millisecond), WithBurnDetector(burner))
+	if !errors.Is(err, ErrBurntPancake) {
+		t.Fatalf("got %v, want %v", err, ErrBurntPancake)
+	}
+	var burnt *BurntPancakeError
+	if !errors.As(err, &burnt) {
+		t.Fatalf("got %v, want a *BurntPancakeError", err)
+	}
+	if burnt.Index != 2 {
+		t.Errorf("pancake %d burnt, want 2", burnt.Index)
+	}
+}
+
+func TestFlipPancakesFailsFirstWithAlwaysBurntDetector(t *testing.T) {
+	mt := useMockTracer(t)
+	var asked atomic.Int64
+	alwaysBurnt := BurnDetectorFunc(func(Cookable, time.Duration) bool {
+		asked.Add(1)
+		return true
+	})
+
+	err := FlipPancakes(context.Background(), breakfast.MakePancakes(3), cleanFlips(), WithCookDuration(time.Millisecond), WithBurnDetector(alwaysBurnt))
+	var burnt *BurntPancakeError
+	if !errors.As(err, &burnt) {
+		t.Fatalf("got %v, want a *BurntPancakeError", err)
+	}
+	if burnt.Index != 0 {
+		t.Errorf("pancake %d burnt, want 0", burnt.Index)
+	}
+	// The detector decided, and the rest of the batch was left alone
+	if n := asked.Load(); n != 1 {
+		t.Errorf("detector asked %d times, want once", n)
+	}
+	for _, span := range breakfasttest.FindSpans(mt, "FlipPancake") {
+		want := "flipped"
+		if span.Tag("pancake.index") == 0 {
+			want = "burnt"
+		}
+		if state := span.Tag("pancake.state"); state != want {
+			t.Errorf("pancake %v state = %v, want %s", span.Tag("pancake.index"), state, want)
+		}
+	}
+}
+
+// stuckError is a pancake stuck to the pan, which may come free if left a
+// moment.
+type stuckError struct{}
+
+func (stuckError) Error() string   { return "stuck to the pan" }
+func (stuckError) Temporary() bool { return true }
+
+// sticksFor has the first n flips fail with err, and later ones succeed.
+// tosses counts the flips.
+func sticksFor(n int64, err error) (opt Option, tosses *atomic.Int64) {
+	tosses = new(atomic.Int64)
+	return func(o *options) {
+		o.toss = func(Cookable) error {
+			if tosses.Add(1) <= n {
+				return err
+			}
+			return nil
+		}
+	}, tosses
+}
+
+func TestFlipPancakesRetriesStuckFlips(t *testing.T) {
+	tests := []struct {
+		name    string
+		sticks  int64
+		err     error
+		tosses  int64
+		retries interface{}
+		state   string
+	}{
+		{name: "unsticks on retry", sticks: 1, err: stuckError{}, tosses: 2, retries: 1, state: "cooked"},
+		{name: "stuck for good", sticks: 100, err: stuckError{}, tosses: 3, retries: 2, state: "raw"},
+		{name: "not worth retrying", sticks: 100, err: errors.New("dropped on the floor"), tosses: 1, retries: nil, state: "raw"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := useMockTracer(t)
+			sticky, tosses := sticksFor(tt.sticks, tt.err)
+
+			err := FlipPancakes(context.Background(), breakfast.MakePancakes(1), sticky, neverBurns(), WithCookDuration(time.Millisecond), WithFlipRetries(2))
+			if tt.state == "cooked" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.state == "raw" && !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if n := tosses.Load(); n != tt.tosses {
+				t.Errorf("flipped %d times, want %d", n, tt.tosses)
+			}
+
+			span := breakfasttest.AssertSpanExists(t, mt, "FlipPancake")
+			if retries := span.Tag("flip.retries"); retries != tt.retries {
+				t.Errorf("flip.retries = %v, want %v", retries, tt.retries)
+			}
+			if state := span.Tag("pancake.state"); state != tt.state {
+				t.Errorf("pancake.state = %v, want %s", state, tt.state)
+			}
+		})
+	}
+}
+
+func TestInitTracerWithSampling(t *testing.T) {
+	t.Setenv("BREAKFAST_REPORTER", "stdout")
+
+	_, _, err := InitTracerWithSampling("sometimes", 1)
+	if err == nil {
+		t.Fatal("an unknown sampler type was accepted")
+	}
+	for _, want := range append([]string{`"sometimes"`}, samplerTypes...) {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %s", err, want)
+		}
+	}
+
+	_, closer, err := InitTracerWithSampling("probabilistic", 0.1)
+	if err != nil {
+		t.Fatalf("probabilistic sampling at 0.1: %v", err)
+	}
+	closer.Close()
+}
+
+func TestSyrupPancakesFixesWhatItCan(t *testing.T) {
+	mt := useMockTracer(t)
+
+	// Pancakes 0 and 1 go soggy. Pancake 0 comes good on its second retry,
+	// pancake 1 never does.
+	var mistakes []breakfast.Pancake
+	got := collect(SyrupPancakes(context.Background(), breakfast.MakePancakes(3), soggyAt(0, 1, 3, 4, 6), WithSyrupRetries(2), WithMistakes(&mistakes)))
+	if len(got) != 2 {
+		t.Errorf("got %d pancakes, want 2", len(got))
+	}
+	if len(mistakes) != 1 {
+		t.Errorf("got %d mistakes, want the 1 that never recovered", len(mistakes))
+	}
+	if recovered := breakfasttest.SpanTag(mt, "FixSoggyPancakes", "pancakes.recovered"); recovered != 1 {
+		t.Errorf("FixSoggyPancakes pancakes.recovered = %v, want 1", recovered)
+	}
+	if soggy := breakfasttest.SpanTag(mt, "FixSoggyPancakes", "pancakes.soggy"); soggy != 1 {
+		t.Errorf("FixSoggyPancakes pancakes.soggy = %v, want 1", soggy)
+	}
+}
+
+func TestForceSampleKeepsUnsampledTrace(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		// A sampler that drops every trace, so only forced ones are reported
+		reporter := jaeger.NewInMemoryReporter()
+		tracer, closer := jaeger.NewTracer("breakfast-test", jaeger.NewConstSampler(false), reporter)
+		prev := opentracing.GlobalTracer()
+		opentracing.SetGlobalTracer(tracer)
+
+		opts := []Option{WithDryRun()}
+		if force {
+			opts = append(opts, WithForceSample())
+		}
+		err := ServeBreakfast(context.Background(), opts...)
+		opentracing.SetGlobalTracer(prev)
+		closer.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var root *jaeger.Span
+		for _, span := range reporter.GetSpans() {
+			if s := span.(*jaeger.Span); s.OperationName() == "ServeHotCakes" {
+				root = s
+			}
+		}
+		switch {
+		case !force && root != nil:
+			t.Error("ServeHotCakes was reported without WithForceSample")
+		case force && root == nil:
+			t.Error("ServeHotCakes wasn't reported with WithForceSample")
+		case force:
+			if !root.SpanContext().IsSampled() {
+				t.Error("forced ServeHotCakes isn't sampled")
+			}
+			if priority := root.Tags()["sampling.priority"]; priority != uint16(1) {
+				t.Errorf("ServeHotCakes sampling.priority = %v, want 1", priority)
+			}
+			// The breakfast's spans inherit the decision
+			if len(reporter.GetSpans()) < 2 {
+				t.Errorf("got %d spans reported, want the whole breakfast", len(reporter.GetSpans()))
+			}
+		}
+	}
+}
+
+func TestServeBreakfastWithTimeoutGivesUp(t *testing.T) {
+	useMockTracer(t)
+
+	// The fake clock never moves, so the pancakes would cook forever
+	start := time.Now()
+	err := ServeBreakfastWithTimeout(100*time.Millisecond, cleanFlips(), WithCookDuration(time.Second), WithClock(NewFakeClock(start)))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if took := time.Since(start); took > 500*time.Millisecond {
+		t.Errorf("gave up after %s, want about 100ms", took)
+	}
+}
+
+func TestDryRunServesQuickly(t *testing.T) {
+	mt := useMockTracer(t)
+
+	start := time.Now()
+	if err := ServeBreakfast(context.Background(), WithDryRun()); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took >= 100*time.Millisecond {
+		t.Errorf("dry run took %s, want well under 100ms", took)
+	}
+
+	for _, name := range []string{"ServeHotCakes", "Pancakes", "FlipPancakes", "EatPancakes"} {
+		breakfasttest.AssertSpanCount(t, mt, name, 1)
+	}
+	breakfasttest.AssertSpanCount(t, mt, "FlipPancake", defaultPancakes)
+	breakfasttest.AssertSpanCount(t, mt, "SyrupPancake", defaultPancakes)
+	waitForEvent(t, mt, "PancakeReady")
+}
+
+func TestRunLoopServesAtItsInterval(t *testing.T) {
+	useMockTracer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	served := make(chan int, 1)
+	go func() { served <- runLoop(ctx, NewKitchen(1, WithDryRun()), 50*time.Millisecond) }()
+
+	// One straight away, then one every 50ms
+	time.Sleep(230 * time.Millisecond)
+	cancel()
+	select {
+	case n := <-served:
+		if n < 3 || n > 6 {
+			t.Errorf("served %d breakfasts in 230ms, want about 5", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("runLoop kept serving after being cancelled")
+	}
+}
+
+func TestRootSpanSummarizesBreakfast(t *testing.T) {
+	mt := useMockTracer(t)
+
+	if err := ServeBreakfast(context.Background(), WithDryRun(), WithPancakeCount(4), WithCoffee(0)); err != nil {
+		t.Fatal(err)
+	}
+	root := breakfasttest.AssertSpanExists(t, mt, "ServeHotCakes")
+	for key, want := range map[string]interface{}{
+		"pancakes.count":   4,
+		"pancakes.burnt":   int64(0),
+		"pancakes.syruped": int64(4),
+	} {
+		if got := root.Tag(key); got != want {
+			t.Errorf("ServeHotCakes %s = %v, want %v", key, got, want)
+		}
+	}
+	if _, ok := root.Tag("duration_ms").(int64); !ok {
+		t.Errorf("ServeHotCakes duration_ms = %v, want a duration", root.Tag("duration_ms"))
+	}
+	if tput, _ := root.Tag("throughput_per_sec").(float64); tput <= 0 {
+		t.Errorf("ServeHotCakes throughput_per_sec = %v, want a positive rate", root.Tag("throughput_per_sec"))
+	}
+}
+
+func TestFlipPancakesLogsEachStep(t *testing.T) {
+	mt := useMockTracer(t)
+
+	if err := FlipPancakes(context.Background(), breakfast.MakePancakes(2), WithDryRun()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"flipped", "cooked", "burn_check"}
+	for _, span := range breakfasttest.FindSpans(mt, "FlipPancake") {
+		var events []string
+		for _, rec := range span.Logs() {
+			for _, f := range rec.Fields {
+				if f.Key == "event" {
+					events = append(events, f.ValueString)
+				}
+			}
+		}
+		if strings.Join(events, ",") != strings.Join(want, ",") {
+			t.Errorf("pancake %v logged %v, want %v", span.Tag("pancake.index"), events, want)
+		}
+	}
+}
+
+func TestFlipPancakesCancelledBeforeStarting(t *testing.T) {
+	mt := useMockTracer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var flips atomic.Int64
+	counted := func(o *options) {
+		o.toss = func(Cookable) error {
+			flips.Add(1)
+			return nil
+		}
+	}
+	if err := FlipPancakes(ctx, breakfast.MakePancakes(3), counted); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	if n := flips.Load(); n != 0 {
+		t.Errorf("flipped %d pancakes after being cancelled, want none", n)
+	}
+	if cancelled, _ := eventField(mt, "FlipPancakes", "cancelled"); cancelled != "true" {
+		t.Errorf("FlipPancakes cancelled = %q, want true", cancelled)
+	}
+}
+
+func TestReporterConfigFromEnv(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		host, port, url string
+		agent           string
+		collector       string
+	}{
+		{name: "unset"},
+		{name: "agent", host: "jaeger-agent", port: "6832", agent: "jaeger-agent:6832"},
+		{name: "agent host only", host: "jaeger-agent", agent: "jaeger-agent:" + defaultAgentPort},
+		{name: "agent port only", port: "6832", agent: "localhost:6832"},
+		{name: "collector", host: "jaeger-agent", url: "http://collector:14268/api/traces", collector: "http://collector:14268/api/traces"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("JAEGER_AGENT_HOST", tc.host)
+			t.Setenv("JAEGER_AGENT_PORT", tc.port)
+			t.Setenv("JAEGER_ENDPOINT", tc.url)
+
+			cfg := reporterConfig()
+			if cfg.LocalAgentHostPort != tc.agent {
+				t.Errorf("agent = %q, want %q", cfg.LocalAgentHostPort, tc.agent)
+			}
+			if cfg.CollectorEndpoint != tc.collector {
+				t.Errorf("collector = %q, want %q", cfg.CollectorEndpoint, tc.collector)
+			}
+		})
+	}
+}
+
+func TestSyrupPancakeSpansSaySoggy(t *testing.T) {
+	mt := useMockTracer(t)
+
+	collect(SyrupPancakes(context.Background(), breakfast.MakePancakes(4), soggyAt(1, 3)))
+	spans := breakfasttest.FindSpans(mt, "SyrupPancake")
+	if len(spans) != 4 {
+		t.Fatalf("got %d SyrupPancake spans, want one per pancake", len(spans))
+	}
+	for _, span := range spans {
+		p, _ := span.Tag("pancake.index").(int)
+		if soggy, want := span.Tag("soggy"), p == 1 || p == 3; soggy != want {
+			t.Errorf("pancake %d soggy = %v, want %v", p, soggy, want)
+		}
+		if _, ok := span.Tag("absorption_ms").(float64); !ok {
+			t.Errorf("pancake %d has no absorption_ms", p)
+		}
+	}
+}
+
+func TestFlipPancakesTimesEachPancake(t *testing.T) {
+	mt := useMockTracer(t)
+
+	if err := FlipPancakes(context.Background(), breakfast.MakePancakes(3), cleanFlips(), neverBurns(), WithCookDuration(5*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	spans := breakfasttest.FindSpans(mt, "FlipPancake")
+	if len(spans) != 3 {
+		t.Fatalf("got %d FlipPancake spans, want 3", len(spans))
+	}
+	for _, span := range spans {
+		if ms, ok := span.Tag("cook_time_ms").(int64); !ok || ms < 5 {
+			t.Errorf("pancake %v cook_time_ms = %v, want at least 5", span.Tag("pancake.index"), span.Tag("cook_time_ms"))
+		}
+	}
+}
+
+func BenchmarkSyrupPancakesBuffer(b *testing.B) {
+	for _, buffer := range []int{0, 16, 64} {
+		b.Run(fmt.Sprintf("buffer=%d", buffer), func(b *testing.B) {
+			cakes := breakfast.MakePancakes(MaxBatchSize)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for range SyrupPancakes(context.Background(), cakes, WithDryRun(), WithBuffer(buffer)) {
+				}
+			}
+			b.ReportMetric(float64(b.N*len(cakes))/b.Elapsed().Seconds(), "pancakes/s")
+		})
+	}
+}
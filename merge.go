@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	opentracing "github.com/opentracing/opentracing-go"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+// MergePancakes fans the pancakes from every channel in chans into one. The
+// returned channel is closed once all of them are, or once ctx is done. The
+// merge runs under a span that finishes when the output is closed, which may
+// be after the caller's span, so it follows from that span.
+func MergePancakes(ctx context.Context, chans ...<-chan breakfast.Pancake) <-chan breakfast.Pancake {
+	span := StartFollowUpSpan(opentracing.SpanFromContext(ctx), "MergePancakes")
+	if id, ok := OrderIDFromContext(ctx); ok {
+		span.SetTag(orderIDTag, id)
+	}
+	span.SetTag("merge.inputs", len(chans))
+
+	out := make(chan breakfast.Pancake)
+	var merged atomic.Int64
+	var wg sync.WaitGroup
+	for _, in := range chans {
+		wg.Add(1)
+		go func(in <-chan breakfast.Pancake) {
+			defer wg.Done()
+			for {
+				select {
+				case cake, ok := <-in:
+					if !ok {
+						return
+					}
+					select {
+					case out <- cake:
+						merged.Add(1)
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(in)
+	}
+
+	go func() {
+		wg.Wait()
+		if ctx.Err() != nil {
+			span.SetTag("cancelled", true)
+		}
+		span.SetTag("pancakes.merged", merged.Load())
+		span.Finish()
+		close(out)
+	}()
+	return out
+}
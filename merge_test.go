@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+// griddleOutput sends n pancakes on a channel it then closes.
+func griddleOutput(n int) <-chan breakfast.Pancake {
+	out := make(chan breakfast.Pancake)
+	go func() {
+		defer close(out)
+		for _, cake := range breakfast.MakePancakes(n) {
+			out <- cake
+		}
+	}()
+	return out
+}
+
+func TestMergePancakes(t *testing.T) {
+	mt := useMockTracer(t)
+
+	merged := MergePancakes(context.Background(), griddleOutput(1), griddleOutput(3), griddleOutput(6))
+	// collect only returns once the merged channel is closed
+	if got := collect(merged); len(got) != 10 {
+		t.Errorf("got %d pancakes, want all 10 exactly once", len(got))
+	}
+	breakfasttest.AssertSpanCount(t, mt, "MergePancakes", 1)
+	if got := breakfasttest.SpanTag(mt, "MergePancakes", "pancakes.merged"); got != int64(10) {
+		t.Errorf("MergePancakes pancakes.merged = %v, want 10", got)
+	}
+}
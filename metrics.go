@@ -0,0 +1,78 @@
+package main
+
+import (
+	"time"
+
+	prometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics counts pancake outcomes, and times each pancake's stages, across
+// every breakfast it is passed to with WithMetrics. Prometheus collectors are
+// safe for concurrent use, so one Metrics can be shared by concurrent
+// breakfasts and worker pools.
+type Metrics struct {
+	Cooked prometheus.Counter
+	Burnt  prometheus.Counter
+	Soggy  prometheus.Counter
+	// How long each pancake spent in each stage: flip, syrup and eat
+	StageLatency *prometheus.HistogramVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		Cooked: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "pancakes_cooked_total",
+			Help: "Pancakes flipped and cooked without burning.",
+		}),
+		Burnt: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "pancakes_burnt_total",
+			Help: "Pancakes that burnt while cooking.",
+		}),
+		Soggy: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "pancakes_soggy_total",
+			Help: "Pancakes that went soggy when syruped.",
+		}),
+		StageLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "pancake_stage_seconds",
+			Help: "How long a pancake spent in each stage.",
+			// 1ms up to about 4s
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 13),
+		}, []string{"stage"}),
+	}
+}
+
+// Register adds the counters to r.
+func (m *Metrics) Register(r prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{m.Cooked, m.Burnt, m.Soggy, m.StageLatency} {
+		if err := r.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The helpers below are safe to call on a nil *Metrics, which counts nothing.
+
+func (m *Metrics) cooked() {
+	if m != nil {
+		m.Cooked.Inc()
+	}
+}
+
+func (m *Metrics) burnt() {
+	if m != nil {
+		m.Burnt.Inc()
+	}
+}
+
+func (m *Metrics) soggy() {
+	if m != nil {
+		m.Soggy.Inc()
+	}
+}
+
+func (m *Metrics) observe(stage string, d time.Duration) {
+	if m != nil {
+		m.StageLatency.WithLabelValues(stage).Observe(d.Seconds())
+	}
+}
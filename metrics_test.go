@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	prometheus "github.com/prometheus/client_golang/prometheus"
+	testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+// registeredMetrics returns Metrics registered with a registry of their own.
+func registeredMetrics(t *testing.T) *Metrics {
+	t.Helper()
+	m := NewMetrics()
+	if err := m.Register(prometheus.NewRegistry()); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// stageHistogram reads back what m has observed for stage.
+func stageHistogram(t *testing.T, m *Metrics, stage string) *dto.Histogram {
+	t.Helper()
+	var pb dto.Metric
+	if err := m.StageLatency.WithLabelValues(stage).(prometheus.Metric).Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	return pb.GetHistogram()
+}
+
+func TestMetricsCountSoggyPancakes(t *testing.T) {
+	useMockTracer(t)
+	m := registeredMetrics(t)
+
+	collect(SyrupPancakes(context.Background(), breakfast.MakePancakes(5), soggyAt(1, 3), WithMetrics(m)))
+	if soggy := testutil.ToFloat64(m.Soggy); soggy != 2 {
+		t.Errorf("counted %v soggy pancakes, want 2", soggy)
+	}
+}
+
+func TestMetricsObserveStageLatency(t *testing.T) {
+	m := registeredMetrics(t)
+	for _, d := range []time.Duration{10 * time.Millisecond, 30 * time.Millisecond, 2 * time.Second} {
+		m.observe("flip", d)
+	}
+	m.observe("syrup", 5*time.Millisecond)
+
+	flip := stageHistogram(t, m, "flip")
+	if n := flip.GetSampleCount(); n != 3 {
+		t.Errorf("flip has %d samples, want 3", n)
+	}
+	if sum := flip.GetSampleSum(); math.Abs(sum-2.04) > 1e-9 {
+		t.Errorf("flip samples sum to %vs, want 2.04s", sum)
+	}
+	// Only the 10ms and 30ms flips fit under the 32ms bucket
+	found := false
+	for _, b := range flip.GetBucket() {
+		if b.GetUpperBound() != 0.032 {
+			continue
+		}
+		found = true
+		if b.GetCumulativeCount() != 2 {
+			t.Errorf("flip has %d samples under 32ms, want 2", b.GetCumulativeCount())
+		}
+	}
+	if !found {
+		t.Error("no 32ms bucket")
+	}
+
+	syrup := stageHistogram(t, m, "syrup")
+	if n := syrup.GetSampleCount(); n != 1 {
+		t.Errorf("syrup has %d samples, want 1", n)
+	}
+	if sum := syrup.GetSampleSum(); math.Abs(sum-0.005) > 1e-9 {
+		t.Errorf("syrup samples sum to %vs, want 0.005s", sum)
+	}
+}
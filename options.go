@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	otlog "github.com/opentracing/opentracing-go/log"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+// Option configures how breakfast is served.
+type Option func(*options)
+
+// Defaults for a breakfast served without options
+const (
+	defaultPancakes = 3
+	defaultHeat     = 1.0
+	defaultSyrup    = "maple"
+	defaultCoffee   = 1
+)
+
+// How long a pancake cooks at the default heat
+const cookTime = 1 * time.Second
+
+// How long to leave an item before trying to flip it again
+const flipRetryPause = 100 * time.Millisecond
+
+// Heat levels to pass to WithHeat
+const (
+	HeatLow    = 0.5
+	HeatMedium = 1.0
+	HeatHigh   = 2.0
+)
+
+// How much likelier a pancake is to burn for each step of heat above medium
+const overheatBurnChance = 0.2
+
+// heatLevel names the heat h is closest to, for tagging spans.
+func heatLevel(h float64) string {
+	switch {
+	case h < (HeatLow+HeatMedium)/2:
+		return "low"
+	case h < (HeatMedium+HeatHigh)/2:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+type options struct {
+	pancakes          int
+	heat              float64
+	syrup             string
+	coffee            int
+	table             string
+	metrics           *Metrics
+	syrupRetries      int
+	maxMistakes       int
+	mistakes          *[]breakfast.Pancake
+	dryRun            bool
+	tally             *tally
+	clock             Clock
+	buffer            int
+	appetite          int
+	forceSample       bool
+	continueOnError   bool
+	spanOpts          []opentracing.StartSpanOption
+	writeTimeout      time.Duration
+	rand              *lockedRand
+	onFlip            func(index int, p breakfast.Pancake)
+	cookDuration      time.Duration
+	burnCheckInterval time.Duration
+	burnDetector      BurnDetector
+	flipRetries       int
+	limiter           chan struct{}
+	// The kitchen whose griddles cook the pancakes, if it isn't FlipPancakes
+	kitchen *Kitchen
+	// Syrups a pancake in place of its own Syrup method, so tests can pick
+	// which pancakes go soggy
+	pour func(*breakfast.Pancake) error
+	// Flips an item in place of its own Flip method, likewise
+	toss func(Cookable) error
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		pancakes: defaultPancakes,
+		heat:     defaultHeat,
+		syrup:    defaultSyrup,
+		coffee:   defaultCoffee,
+		clock:    RealClock{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// cookTime is how long pancakes cook, at the chosen heat unless a cook
+// duration was set.
+func (o *options) cookTime() time.Duration {
+	if o.dryRun {
+		return 0
+	}
+	if o.cookDuration > 0 {
+		return o.cookDuration
+	}
+	return time.Duration(float64(cookTime) / o.heat)
+}
+
+func (o *options) brewTime() time.Duration {
+	if o.dryRun {
+		return 0
+	}
+	return brewTime
+}
+
+// flip, isBurnt and syrupPancake stand in for the item's own methods so that
+// a dry run can pretend everything went perfectly.
+
+// flip also tries again, up to the WithFlipRetries limit, when the item
+// fails with an error that says it is temporary, noting each retry on span.
+func (o *options) flip(ctx context.Context, span opentracing.Span, c Cookable) error {
+	if o.dryRun {
+		return nil
+	}
+	flip := c.Flip
+	if o.toss != nil {
+		flip = func() error { return o.toss(c) }
+	}
+	err := o.limited(flip)
+	for try := 1; try <= o.flipRetries && temporary(err); try++ {
+		span.SetTag("flip.retries", try)
+		span.LogFields(otlog.String("event", "flip_retry"), otlog.Error(err))
+		select {
+		case <-o.clock.After(flipRetryPause):
+		case <-ctx.Done():
+			return err
+		}
+		err = o.limited(flip)
+	}
+	return err
+}
+
+// temporary reports whether err says it may go away if tried again.
+func temporary(err error) bool {
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
+
+func (o *options) isBurnt(c Cookable, cooked time.Duration) bool {
+	if o.dryRun {
+		return false
+	}
+	if o.burnDetector != nil {
+		return o.burnDetector.Burnt(c, cooked)
+	}
+	return c.IsBurnt() || o.overheated()
+}
+
+// overheated reports whether a hotter than medium griddle burnt an item that
+// would otherwise have been fine.
+func (o *options) overheated() bool {
+	if o.heat <= HeatMedium {
+		return false
+	}
+	return o.float64() < overheatBurnChance*(o.heat-HeatMedium)
+}
+
+// flipped tells the WithOnFlip callback, if any, that the pancake at index
+// has been flipped.
+func (o *options) flipped(index int, c Cookable) {
+	if o.onFlip == nil {
+		return
+	}
+	if cake, ok := c.(*breakfast.Pancake); ok {
+		o.onFlip(index, *cake)
+	}
+}
+
+func (o *options) syrupPancake(p *breakfast.Pancake) error {
+	if o.dryRun {
+		return nil
+	}
+	pour := p.Syrup
+	if o.pour != nil {
+		pour = func() error { return o.pour(p) }
+	}
+	err := o.limited(pour)
+	if err == nil {
+		o.pouredSyrup()
+	}
+	return err
+}
+
+// limited runs op once the WithMaxConcurrency limit, if any, allows. Slots
+// are only held for a single flip or syrup, so the wait is short.
+func (o *options) limited(op func() error) error {
+	if o.limiter == nil {
+		return op()
+	}
+	o.limiter <- struct{}{}
+	defer func() { <-o.limiter }()
+	return op()
+}
+
+// WithPancakeCount serves n pancakes instead of 3.
+func WithPancakeCount(n int) Option {
+	return func(o *options) {
+		o.pancakes = n
+	}
+}
+
+// WithHeat scales how hot the griddle is, HeatMedium being normal. Pancakes
+// cook in 1/h of the normal time, but above medium they burn more often.
+// Non-positive values are ignored.
+func WithHeat(h float64) Option {
+	return func(o *options) {
+		if h > 0 {
+			o.heat = h
+		}
+	}
+}
+
+// WithSyrup picks the syrup poured on the pancakes, maple by default.
+func WithSyrup(kind string) Option {
+	return func(o *options) {
+		o.syrup = kind
+	}
+}
+
+// WithCoffee brews cups of coffee alongside the pancakes, 0 for none.
+func WithCoffee(cups int) Option {
+	return func(o *options) {
+		o.coffee = cups
+	}
+}
+
+// WithTableNumber labels the whole breakfast trace with the table it is for.
+// The number travels as baggage, so every span below the root can read it.
+func WithTableNumber(table int) Option {
+	return func(o *options) {
+		o.table = strconv.Itoa(table)
+	}
+}
+
+// WithMetrics counts the pancakes cooked, burnt and soggy in m, and times how
+// long each one takes to flip, syrup and eat.
+func WithMetrics(m *Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+// WithSyrupRetries has SyrupPancakes try to fix each soggy pancake up to n
+// times before giving up on it.
+func WithSyrupRetries(n int) Option {
+	return func(o *options) {
+		o.syrupRetries = n
+	}
+}
+
+// WithMaxMistakes has SyrupPancakes give up on the batch once more than n
+// pancakes have gone soggy. 0, the default, never gives up.
+func WithMaxMistakes(n int) Option {
+	return func(o *options) {
+		o.maxMistakes = n
+	}
+}
+
+// WithMistakes stores the pancakes SyrupPancakes couldn't fix in dst. It is
+// set by the time the output channel is closed.
+func WithMistakes(dst *[]breakfast.Pancake) Option {
+	return func(o *options) {
+		o.mistakes = dst
+	}
+}
+
+// WithDryRun serves breakfast without really cooking it: nothing waits and
+// every pancake comes out perfect, but all the spans and events are still
+// emitted. Handy for testing the tracing quickly.
+func WithDryRun() Option {
+	return func(o *options) {
+		o.dryRun = true
+	}
+}
+
+// WithClock cooks by c's time instead of the wall clock's.
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithBuffer lets SyrupPancakes get up to n pancakes ahead of whoever is
+// eating them. A bigger buffer keeps a slow eater from holding up the
+// syruping, at the cost of more pancakes sitting waiting in memory. The
+// default, 0, hands each pancake over as soon as it is syruped.
+func WithBuffer(n int) Option {
+	return func(o *options) {
+		if n >= 0 {
+			o.buffer = n
+		}
+	}
+}
+
+// WithAppetite has whoever is eating leave after n pancakes, which stops the
+// rest being syruped. 0, the default, eats everything.
+func WithAppetite(n int) Option {
+	return func(o *options) {
+		o.appetite = n
+	}
+}
+
+// WithForceSample keeps the breakfast's trace even if the sampler would drop
+// it, e.g. for a VIP table.
+func WithForceSample() Option {
+	return func(o *options) {
+		o.forceSample = true
+	}
+}
+
+// WithContinueOnError has FlipPancakes and FlipPancakesConcurrent cook the
+// whole batch even when some of it fails, and then return a *BatchError
+// listing every failure.
+func WithContinueOnError() Option {
+	return func(o *options) {
+		o.continueOnError = true
+	}
+}
+
+// WithRootSpanOptions starts the breakfast's root span with opts, e.g.
+// opentracing.Tag{Key: "service.version", Value: version}.
+func WithRootSpanOptions(opts ...opentracing.StartSpanOption) Option {
+	return func(o *options) {
+		o.spanOpts = append(o.spanOpts, opts...)
+	}
+}
+
+// WithWriteTimeout has SyrupPancakes and SyrupPancakesConcurrent stop,
+// failing their event with ErrNoConsumer, if a syruped pancake isn't taken
+// within d. 0, the default, waits as long as it takes.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = d
+	}
+}
+
+// WithRandSource makes the kitchen's own chance outcomes, like burns from
+// high heat, come from src, so a breakfast can be repeated exactly.
+func WithRandSource(src rand.Source) Option {
+	// Shared by every stage the option is passed to, so they all take turns
+	// with src
+	r := &lockedRand{r: rand.New(src)}
+	return func(o *options) {
+		o.rand = r
+	}
+}
+
+// WithOnFlip calls fn after each pancake FlipPancakes flips successfully,
+// with its index in the batch. fn is called in order, before FlipPancakes
+// moves on to the next pancake, so it should be quick. FlipPancakesConcurrent
+// calls it from its workers, so there it may be called for several pancakes
+// at once and in any order.
+func WithOnFlip(fn func(index int, p breakfast.Pancake)) Option {
+	return func(o *options) {
+		o.onFlip = fn
+	}
+}
+
+// WithCookDuration cooks for d, whatever the heat.
+func WithCookDuration(d time.Duration) Option {
+	return func(o *options) {
+		o.cookDuration = d
+	}
+}
+
+// WithBurnCheckInterval checks for burning every d while cooking, instead of
+// only at the end, and pulls the batch as soon as anything has burnt.
+func WithBurnCheckInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.burnCheckInterval = d
+	}
+}
+
+// WithBurnDetector decides whether items are burnt with d, instead of asking
+// the items themselves.
+func WithBurnDetector(d BurnDetector) Option {
+	return func(o *options) {
+		o.burnDetector = d
+	}
+}
+
+// WithFlipRetries tries flipping an item up to n more times, after a short
+// pause, when it fails with an error whose Temporary method reports true.
+// Any other error fails the batch straight away.
+func WithFlipRetries(n int) Option {
+	return func(o *options) {
+		o.flipRetries = n
+	}
+}
+
+// WithMaxConcurrency lets at most n flips and syrupings happen at once, across
+// every breakfast and griddle the option is passed to. Give it to NewKitchen
+// to cap the whole kitchen.
+func WithMaxConcurrency(n int) Option {
+	if n < 1 {
+		n = 1
+	}
+	limiter := make(chan struct{}, n)
+	return func(o *options) {
+		o.limiter = limiter
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+func TestWithMaxConcurrencyLimitsSyruping(t *testing.T) {
+	useMockTracer(t)
+
+	// Count how many pancakes are being syruped at once. The first few go
+	// soggy, so the fixing afterwards is limited too.
+	var running, most, poured atomic.Int64
+	instrumented := func(o *options) {
+		o.pour = func(*breakfast.Pancake) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+			}
+			time.Sleep(time.Millisecond)
+			if poured.Add(1) <= 5 {
+				return ErrSoggyPancake
+			}
+			return nil
+		}
+	}
+
+	// Two batches on pools of their own share the limit
+	limit := WithMaxConcurrency(2)
+	var wg sync.WaitGroup
+	for b := 0; b < 2; b++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ready := SyrupPancakesConcurrent(context.Background(), breakfast.MakePancakes(10), 8, limit, instrumented, WithSyrupRetries(1))
+			if got := collect(ready); len(got) != 10 {
+				t.Errorf("got %d pancakes, want all 10 fixed", len(got))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := most.Load(); n > 2 {
+		t.Errorf("%d pancakes were syruped at once, want at most 2", n)
+	}
+}
+
+func TestFixSoggyPancakesDryRun(t *testing.T) {
+	useMockTracer(t)
+
+	fixed, soggy := FixSoggyPancakes(context.Background(), breakfast.MakePancakes(3), 1, WithDryRun())
+	if len(fixed) != 3 || len(soggy) != 0 {
+		t.Errorf("fixed %d and left %d soggy, want every pancake fixed in a dry run", len(fixed), len(soggy))
+	}
+}
+
+func TestWithTableNumberReachesEveryStage(t *testing.T) {
+	mt := useMockTracer(t)
+
+	if err := ServeBreakfast(context.Background(), WithDryRun(), WithTableNumber(7)); err != nil {
+		t.Fatal(err)
+	}
+	// Eating is below the Pancakes span, so the table came down as baggage
+	if got := breakfasttest.SpanTag(mt, "EatPancakes", tableBaggageKey); got != "7" {
+		t.Errorf("EatPancakes %s = %v, want 7", tableBaggageKey, got)
+	}
+}
+
+func TestServeBreakfastOptions(t *testing.T) {
+	mt := useMockTracer(t)
+
+	summary, err := ServeBreakfastWithSummary(context.Background(), WithDryRun(), WithPancakeCount(5), WithHeat(HeatHigh), WithSyrup("blueberry"), WithCoffee(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Served != 5 {
+		t.Errorf("served %d pancakes, want 5", summary.Served)
+	}
+	breakfasttest.AssertSpanCount(t, mt, "FlipPancake", 5)
+	if heat := breakfasttest.SpanTag(mt, "FlipPancake", "heat"); heat != "high" {
+		t.Errorf("FlipPancake heat = %v, want high", heat)
+	}
+	if syrup, _ := eventField(mt, "PancakeReady", "syrup"); syrup != "blueberry" {
+		t.Errorf("PancakeReady syrup = %q, want blueberry", syrup)
+	}
+	breakfasttest.AssertSpanCount(t, mt, "BrewCoffee", 0)
+}
+
+func TestWithOnFlipSeesEachFlippedPancake(t *testing.T) {
+	flips := map[string]func(ctx context.Context, cakes []breakfast.Pancake, opts ...Option) error{
+		"FlipPancakes": FlipPancakes,
+		"FlipPancakesConcurrent": func(ctx context.Context, cakes []breakfast.Pancake, opts ...Option) error {
+			return FlipPancakesConcurrent(ctx, cakes, 3, opts...)
+		},
+	}
+	for name, flip := range flips {
+		t.Run(name, func(t *testing.T) {
+			useMockTracer(t)
+			cakes := breakfast.MakePancakes(6)
+
+			// Pancakes 2 and 5 stick to the pan and never flip
+			stuck := errors.New("stuck to the pan")
+			sticky := func(o *options) {
+				o.toss = func(c Cookable) error {
+					if c == Cookable(&cakes[2]) || c == Cookable(&cakes[5]) {
+						return stuck
+					}
+					return nil
+				}
+			}
+			var mu sync.Mutex
+			var seen []int
+			onFlip := WithOnFlip(func(index int, cake breakfast.Pancake) {
+				mu.Lock()
+				defer mu.Unlock()
+				seen = append(seen, index)
+			})
+
+			err := flip(context.Background(), cakes, sticky, neverBurns(), WithCookDuration(time.Millisecond), WithContinueOnError(), onFlip)
+			if !errors.Is(err, stuck) {
+				t.Fatalf("got %v, want %v", err, stuck)
+			}
+			sort.Ints(seen)
+			want := []int{0, 1, 3, 4}
+			if len(seen) != len(want) {
+				t.Fatalf("OnFlip saw pancakes %v, want %v", seen, want)
+			}
+			for i := range want {
+				if seen[i] != want[i] {
+					t.Fatalf("OnFlip saw pancakes %v, want %v", seen, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+var (
+	ErrNoOrder   = errors.New("no breakfast order in context")
+	ErrQueueFull = errors.New("order queue is full")
+)
+
+type orderKey struct{}
+
+// InjectOrder writes the trace of the order in ctx into carrier, so another
+// service can pick it up with ExtractOrder. The order is the active span in
+// ctx, or one previously extracted into it.
+func InjectOrder(ctx context.Context, carrier map[string]string) error {
+	var order opentracing.SpanContext
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		order = span.Context()
+	} else if sc, ok := orderFromContext(ctx); ok {
+		order = sc
+	} else {
+		return ErrNoOrder
+	}
+	return opentracing.GlobalTracer().Inject(order, opentracing.TextMap, opentracing.TextMapCarrier(carrier))
+}
+
+// ExtractOrder reads an order injected by InjectOrder from carrier. Breakfast
+// served with the returned context continues the order's trace.
+func ExtractOrder(carrier map[string]string) (context.Context, error) {
+	order, err := opentracing.GlobalTracer().Extract(opentracing.TextMap, opentracing.TextMapCarrier(carrier))
+	if err != nil {
+		return nil, err
+	}
+	return contextWithOrder(context.Background(), order), nil
+}
+
+func contextWithOrder(ctx context.Context, order opentracing.SpanContext) context.Context {
+	return context.WithValue(ctx, orderKey{}, order)
+}
+
+func orderFromContext(ctx context.Context) (opentracing.SpanContext, bool) {
+	order, ok := ctx.Value(orderKey{}).(opentracing.SpanContext)
+	return order, ok
+}
+
+// Order is a breakfast someone asked for.
+type Order struct {
+	// Trace is the order's trace as written by InjectOrder. If it is empty
+	// the breakfast starts a trace of its own.
+	Trace map[string]string
+	// Options to serve the breakfast with
+	Options []Option
+}
+
+// OrderQueue holds orders waiting for the kitchen. When it is full new orders
+// are turned away rather than piling up.
+type OrderQueue struct {
+	orders chan Order
+}
+
+// NewOrderQueue returns a queue with room for size orders.
+func NewOrderQueue(size int) *OrderQueue {
+	return &OrderQueue{orders: make(chan Order, size)}
+}
+
+// Enqueue adds an order to the queue, or returns ErrQueueFull if there is no
+// room for it.
+func (q *OrderQueue) Enqueue(order Order) error {
+	select {
+	case q.orders <- order:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Serve serves queued orders one at a time, each in its own trace, until ctx
+// is cancelled.
+func (q *OrderQueue) Serve(ctx context.Context) error {
+	for {
+		select {
+		case order := <-q.orders:
+			serveOrder(ctx, order)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// ServeMany serves the orders, at most concurrency at a time, each in its own
+// trace. The errors line up with the orders. Once ctx is cancelled no more
+// orders are started, and those left fail with ctx's error.
+func ServeMany(ctx context.Context, orders []Order, concurrency int) []error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, len(orders))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, order := range orders {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(orders); j++ {
+				errs[j] = ctx.Err()
+			}
+			wg.Wait()
+			return errs
+		}
+		wg.Add(1)
+		go func(i int, order Order) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			errs[i] = serveOrder(ctx, order)
+		}(i, order)
+	}
+	wg.Wait()
+	return errs
+}
+
+// serveOrder serves a single order, continuing its trace if it has one.
+func serveOrder(ctx context.Context, order Order) error {
+	if len(order.Trace) > 0 {
+		sc, err := opentracing.GlobalTracer().Extract(opentracing.TextMap, opentracing.TextMapCarrier(order.Trace))
+		if err != nil {
+			log.Warningf("Serving order without its trace: %s", err)
+		} else {
+			ctx = contextWithOrder(ctx, sc)
+		}
+	}
+	summary, err := ServeBreakfastWithSummary(ctx, order.Options...)
+	logBreakfast(ctx, err, newOptions(order.Options).pancakes, summary)
+	return err
+}
+
+type orderIDKey struct{}
+
+// Tag the order ID is recorded under on spans and events
+const orderIDTag = "order.id"
+
+// ContextWithOrderID attaches an order ID from an outside ordering system to
+// ctx. Unlike the trace, it is meaningful to that system, and the breakfast
+// spans are tagged with it so they can be found from an order.
+func ContextWithOrderID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, orderIDKey{}, id)
+}
+
+// OrderIDFromContext returns the order ID attached to ctx, if there is one.
+func OrderIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(orderIDKey{}).(string)
+	return id, ok
+}
+
+// startSpan starts a span below the one in ctx, tagged with ctx's order ID.
+func startSpan(ctx context.Context, name string) (opentracing.Span, context.Context) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, name)
+	if id, ok := OrderIDFromContext(ctx); ok {
+		span.SetTag(orderIDTag, id)
+	}
+	return span, ctx
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	mocktracer "github.com/opentracing/opentracing-go/mocktracer"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+func TestOrderCarriesTrace(t *testing.T) {
+	mt := useMockTracer(t)
+
+	placed := StartRootSpan("PlaceOrder")
+	carrier := map[string]string{}
+	if err := InjectOrder(opentracing.ContextWithSpan(context.Background(), placed), carrier); err != nil {
+		t.Fatal(err)
+	}
+	placed.Finish()
+
+	ctx, err := ExtractOrder(carrier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ServeBreakfast(ctx, WithDryRun()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := placed.Context().(mocktracer.MockSpanContext)
+	root := breakfasttest.AssertSpanExists(t, mt, "ServeHotCakes")
+	if root.SpanContext.TraceID != want.TraceID {
+		t.Errorf("breakfast is in trace %d, want the order's %d", root.SpanContext.TraceID, want.TraceID)
+	}
+	if root.ParentID != want.SpanID {
+		t.Errorf("breakfast's parent is span %d, want the order's %d", root.ParentID, want.SpanID)
+	}
+}
+
+func TestOrderQueueTurnsAwayOrdersWhenFull(t *testing.T) {
+	q := NewOrderQueue(2)
+	for i := 0; i < 2; i++ {
+		if err := q.Enqueue(Order{}); err != nil {
+			t.Fatalf("order %d: %v", i, err)
+		}
+	}
+	if err := q.Enqueue(Order{}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("third order got %v, want %v", err, ErrQueueFull)
+	}
+}
+
+func TestOrderIDTagsFlips(t *testing.T) {
+	mt := useMockTracer(t)
+
+	ctx := ContextWithOrderID(context.Background(), "order-42")
+	if err := FlipPancakes(ctx, breakfast.MakePancakes(2), WithDryRun()); err != nil {
+		t.Fatal(err)
+	}
+	spans := breakfasttest.FindSpans(mt, "FlipPancake")
+	if len(spans) != 2 {
+		t.Fatalf("got %d FlipPancake spans, want 2", len(spans))
+	}
+	for _, span := range spans {
+		if id := span.Tag(orderIDTag); id != "order-42" {
+			t.Errorf("pancake %v %s = %v, want order-42", span.Tag("pancake.index"), orderIDTag, id)
+		}
+	}
+	if id, _ := eventField(mt, "FlipPancakes", orderIDTag); id != "order-42" {
+		t.Errorf("FlipPancakes %s = %q, want order-42", orderIDTag, id)
+	}
+}
+
+func TestServeManyServesEveryOrder(t *testing.T) {
+	mt := useMockTracer(t)
+
+	// Orders 1 and 4 burn their first pancake, the rest are dry runs
+	burnt := []Option{cleanFlips(), WithCookDuration(time.Millisecond), WithBurnDetector(BurnDetectorFunc(func(Cookable, time.Duration) bool { return true }))}
+	orders := make([]Order, 6)
+	for i := range orders {
+		orders[i].Options = []Option{WithDryRun()}
+		if i == 1 || i == 4 {
+			orders[i].Options = burnt
+		}
+	}
+
+	errs := ServeMany(context.Background(), orders, 2)
+	if len(errs) != len(orders) {
+		t.Fatalf("got %d errors, want one per order", len(errs))
+	}
+	for i, err := range errs {
+		var b *BurntPancakeError
+		switch {
+		case i == 1 || i == 4:
+			if !errors.As(err, &b) {
+				t.Errorf("order %d got %v, want a burnt pancake", i, err)
+			}
+		case err != nil:
+			t.Errorf("order %d: %v", i, err)
+		}
+	}
+
+	spans := breakfasttest.FindSpans(mt, "ServeHotCakes")
+	if len(spans) != len(orders) {
+		t.Fatalf("got %d ServeHotCakes spans, want %d", len(spans), len(orders))
+	}
+	// No more than two breakfasts were ever cooking at once
+	for _, span := range spans {
+		overlapping := 0
+		for _, other := range spans {
+			if !other.StartTime.After(span.StartTime) && other.FinishTime.After(span.StartTime) {
+				overlapping++
+			}
+		}
+		if overlapping > 2 {
+			t.Errorf("%d breakfasts cooking at once, want at most 2", overlapping)
+		}
+	}
+}
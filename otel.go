@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"io"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/attribute"
+	otbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// InitOTelTracer initializes an OpenTelemetry tracer that exports spans over
+// OTLP, configured by the standard OTEL_EXPORTER_OTLP_* environment variables.
+// It is wrapped in the OpenTracing bridge, so everything that works with the
+// Jaeger tracer, go-log's events included, works with it unchanged.
+func InitOTelTracer() (opentracing.Tracer, io.Closer, error) {
+	exporter, err := otlptracegrpc.New(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "Breakfast"))),
+	)
+
+	// Shutting the provider down flushes any batched spans
+	closer := closerFunc(func() error {
+		return provider.Shutdown(context.Background())
+	})
+	return bridgeTracer(provider), closer, nil
+}
+
+// bridgeTracer wraps the provider's tracer in the OpenTracing bridge.
+func bridgeTracer(provider *sdktrace.TracerProvider) opentracing.Tracer {
+	tracer, _ := otbridge.NewTracerPair(provider.Tracer("breakfast"))
+	// Without a propagator the bridge can't inject or extract a trace, so
+	// orders would never continue the caller's trace. Carry it, and its
+	// baggage like the table number, in the W3C headers.
+	tracer.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tracer
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
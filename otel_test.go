@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestServeBreakfastThroughTheOTelBridge(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(bridgeTracer(provider))
+	t.Cleanup(func() { opentracing.SetGlobalTracer(prev) })
+
+	if err := ServeBreakfast(context.Background(), WithDryRun(), WithPancakeCount(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := make(map[string]int)
+	for _, span := range recorder.Ended() {
+		recorded[span.Name()]++
+	}
+	if recorded["ServeHotCakes"] != 1 {
+		t.Errorf("recorded %d ServeHotCakes spans, want 1", recorded["ServeHotCakes"])
+	}
+	if recorded["FlipPancake"] != 2 {
+		t.Errorf("recorded %d FlipPancake spans, want one per pancake", recorded["FlipPancake"])
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+var ErrEmptyPlate = errors.New("nothing on the plate")
+
+// Calories in a pancake, and in the syrup poured on it
+const (
+	DryPancakeCalories = 90
+	SyrupCalories      = 60
+	PancakeCalories    = DryPancakeCalories + SyrupCalories
+)
+
+// Nutrition is the macronutrients in some food, in grams.
+type Nutrition struct {
+	Carbs   float64
+	Protein float64
+	Fat     float64
+}
+
+func (n Nutrition) Add(m Nutrition) Nutrition {
+	return Nutrition{Carbs: n.Carbs + m.Carbs, Protein: n.Protein + m.Protein, Fat: n.Fat + m.Fat}
+}
+
+// Times is n scaled by k, e.g. for k servings.
+func (n Nutrition) Times(k float64) Nutrition {
+	return Nutrition{Carbs: n.Carbs * k, Protein: n.Protein * k, Fat: n.Fat * k}
+}
+
+// SyrupNutrition is what the syrup on a pancake adds, all sugar
+var SyrupNutrition = Nutrition{Carbs: 15}
+
+// PancakeNutrition is what is in one syruped pancake.
+func PancakeNutrition() Nutrition {
+	return pancakeRecipe.Nutrition().Add(SyrupNutrition)
+}
+
+// TotalCalories is the calories in the pancakes themselves, without their
+// syrup. A Pancake doesn't say whether it was syruped, so add SyrupCalories
+// for each one that was.
+func TotalCalories(cakes []breakfast.Pancake) int {
+	return len(cakes) * DryPancakeCalories
+}
+
+// Plate collects finished pancakes until they are served.
+type Plate struct {
+	cakes []breakfast.Pancake
+	// How many of cakes were syruped
+	syruped int
+}
+
+// Add puts a syruped pancake on the plate.
+func (pl *Plate) Add(p breakfast.Pancake) {
+	pl.cakes = append(pl.cakes, p)
+	pl.syruped++
+}
+
+// AddDry puts a pancake on the plate that was never syruped, e.g. in a dry
+// run.
+func (pl *Plate) AddDry(p breakfast.Pancake) {
+	pl.cakes = append(pl.cakes, p)
+}
+
+// Len is how many pancakes are on the plate.
+func (pl *Plate) Len() int {
+	return len(pl.cakes)
+}
+
+// Calories is the total on the plate.
+func (pl *Plate) Calories() int {
+	return TotalCalories(pl.cakes) + pl.syruped*SyrupCalories
+}
+
+// Nutrition is the total on the plate.
+func (pl *Plate) Nutrition() Nutrition {
+	return pancakeRecipe.Nutrition().Times(float64(len(pl.cakes))).
+		Add(SyrupNutrition.Times(float64(pl.syruped)))
+}
+
+// Serve hands over everything on the plate, or ErrEmptyPlate if nothing was
+// put on it.
+func (pl *Plate) Serve() ([]breakfast.Pancake, error) {
+	if len(pl.cakes) == 0 {
+		return nil, ErrEmptyPlate
+	}
+	return pl.cakes, nil
+}
+
+// CloneBatch copies a batch so it can be plated twice without the two plates
+// sharing pancakes. Pancakes are copied by value, which is as deep as
+// breakfast.Pancake lets a copy go from outside its package.
+func CloneBatch(cakes []breakfast.Pancake) []breakfast.Pancake {
+	if cakes == nil {
+		return nil
+	}
+	return append(make([]breakfast.Pancake, 0, len(cakes)), cakes...)
+}
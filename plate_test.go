@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+func TestTotalCalories(t *testing.T) {
+	if got, want := TotalCalories(breakfast.MakePancakes(4)), 4*DryPancakeCalories; got != want {
+		t.Errorf("TotalCalories = %d, want %d", got, want)
+	}
+	if got := TotalCalories(nil); got != 0 {
+		t.Errorf("TotalCalories(nil) = %d, want 0", got)
+	}
+}
+
+func TestPlateCountsSyrupCalories(t *testing.T) {
+	var syruped, dry Plate
+	for _, p := range breakfast.MakePancakes(2) {
+		syruped.Add(p)
+		dry.AddDry(p)
+	}
+	if syruped.Calories() <= dry.Calories() {
+		t.Errorf("syruped plate has %d calories, dry plate %d, want syruped higher", syruped.Calories(), dry.Calories())
+	}
+	if got, want := syruped.Calories(), 2*PancakeCalories; got != want {
+		t.Errorf("syruped plate has %d calories, want %d", got, want)
+	}
+	if got, want := dry.Calories(), 2*DryPancakeCalories; got != want {
+		t.Errorf("dry plate has %d calories, want %d", got, want)
+	}
+}
+
+func TestPlateCountsSyrupCarbs(t *testing.T) {
+	var syruped, dry Plate
+	for _, p := range breakfast.MakePancakes(3) {
+		syruped.Add(p)
+		dry.AddDry(p)
+	}
+	got, plain := syruped.Nutrition(), dry.Nutrition()
+	if got.Carbs <= plain.Carbs {
+		t.Errorf("syruped plate has %vg carbs, dry plate %vg, want syruped higher", got.Carbs, plain.Carbs)
+	}
+	if want := plain.Add(SyrupNutrition.Times(3)); got != want {
+		t.Errorf("syruped plate has %+v, want %+v", got, want)
+	}
+	// Syrup is all sugar
+	if got.Protein != plain.Protein || got.Fat != plain.Fat {
+		t.Errorf("syrup changed protein or fat: %+v, dry %+v", got, plain)
+	}
+}
+
+func TestDryRunBreakfastHasNoSyrupCalories(t *testing.T) {
+	mt := useMockTracer(t)
+
+	if err := ServeBreakfast(context.Background(), WithDryRun(), WithCoffee(0)); err != nil {
+		t.Fatal(err)
+	}
+	want := int64(defaultPancakes * DryPancakeCalories)
+	if got := breakfasttest.SpanTag(mt, "ServeHotCakes", "calories"); got != want {
+		t.Errorf("ServeHotCakes calories = %v, want %d", got, want)
+	}
+}
+
+func TestEmptyPlate(t *testing.T) {
+	var pl Plate
+	if _, err := pl.Serve(); !errors.Is(err, ErrEmptyPlate) {
+		t.Errorf("serving an empty plate got %v, want %v", err, ErrEmptyPlate)
+	}
+}
+
+func TestCloneBatchDoesNotShare(t *testing.T) {
+	batch := breakfast.MakePancakes(2)
+	before := CloneBatch(batch)
+
+	clone := CloneBatch(batch)
+	if &clone[0] == &batch[0] {
+		t.Fatal("the clone shares the batch's pancakes")
+	}
+	for p := range clone {
+		clone[p].Flip()
+		Syrup.Apply(&clone[p])
+	}
+	for p := range batch {
+		if batch[p] != before[p] {
+			t.Errorf("pancake %d changed with its clone", p)
+		}
+	}
+
+	if CloneBatch(nil) != nil {
+		t.Error("cloning no batch made one")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+// MakePancakesInto makes n fresh pancakes in buf and returns them, only
+// allocating if buf is too small. Reusing buf from batch to batch saves
+// allocating a new slice each time.
+func MakePancakesInto(buf []breakfast.Pancake, n int) []breakfast.Pancake {
+	if cap(buf) < n {
+		buf = make([]breakfast.Pancake, n)
+	}
+	buf = buf[:n]
+	for p := range buf {
+		buf[p] = breakfast.Pancake{}
+	}
+	return buf
+}
+
+// PancakeBatch is a batch of pancakes borrowed from a pool with GetPancakes.
+type PancakeBatch struct {
+	Cakes []breakfast.Pancake
+}
+
+var pancakePool = sync.Pool{
+	New: func() any { return new(PancakeBatch) },
+}
+
+// GetPancakes makes n fresh pancakes in a batch from a pool, so that serving
+// breakfast after breakfast doesn't allocate a slice for each. Hand the batch
+// back with Put when done with it.
+func GetPancakes(n int) *PancakeBatch {
+	b := pancakePool.Get().(*PancakeBatch)
+	// Whatever the last breakfast did to them, these are new pancakes
+	b.Cakes = MakePancakesInto(b.Cakes, n)
+	return b
+}
+
+// Put returns the batch to the pool. Neither it nor its pancakes may be used
+// afterwards.
+func (b *PancakeBatch) Put() {
+	pancakePool.Put(b)
+}
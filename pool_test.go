@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+func TestMakePancakesIntoReusesBuffer(t *testing.T) {
+	buf := make([]breakfast.Pancake, 0, 8)
+	cakes := MakePancakesInto(buf, 5)
+	if len(cakes) != 5 {
+		t.Fatalf("made %d pancakes, want 5", len(cakes))
+	}
+	if &cakes[0] != &buf[:1][0] {
+		t.Error("made the pancakes in a new slice, want them in buf")
+	}
+	if allocs := testing.AllocsPerRun(100, func() { MakePancakesInto(buf, 8) }); allocs != 0 {
+		t.Errorf("MakePancakesInto allocated %v times, want 0", allocs)
+	}
+}
+
+func TestPooledPancakesAreFresh(t *testing.T) {
+	b := GetPancakes(4)
+	for p := range b.Cakes {
+		b.Cakes[p].Flip()
+	}
+	b.Put()
+
+	b = GetPancakes(4)
+	defer b.Put()
+	for p, cake := range b.Cakes {
+		if cake != (breakfast.Pancake{}) {
+			t.Errorf("pancake %d came out of the pool already cooked", p)
+		}
+	}
+}
+
+const benchBatch = 64
+
+func BenchmarkMakePancakes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = breakfast.MakePancakes(benchBatch)
+	}
+}
+
+func BenchmarkMakePancakesInto(b *testing.B) {
+	b.ReportAllocs()
+	var buf []breakfast.Pancake
+	for i := 0; i < b.N; i++ {
+		buf = MakePancakesInto(buf, benchBatch)
+	}
+}
+
+func BenchmarkGetPancakes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		GetPancakes(benchBatch).Put()
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// lockedRand is a rand.Rand that is safe for concurrent use, as the global
+// one is, since cooking may happen on several goroutines.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+// float64 draws from the source set with WithRandSource, or the global one.
+func (o *options) float64() float64 {
+	if o.rand == nil {
+		return rand.Float64()
+	}
+	return o.rand.Float64()
+}
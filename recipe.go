@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+
+	ext "github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// Recipe is one item on the breakfast menu.
+type Recipe interface {
+	// Name of the item, also used to name its span
+	Name() string
+	// Prepare cooks the item and serves it
+	Prepare(ctx context.Context) error
+}
+
+// Breakfast is everything that goes on the table for one order.
+type Breakfast struct {
+	Items []Recipe
+}
+
+// Serve prepares each item in turn under a span named after it, stopping at
+// the first one that fails.
+func (b *Breakfast) Serve(ctx context.Context) error {
+	for _, item := range b.Items {
+		span, ictx := startSpan(ctx, item.Name())
+		err := item.Prepare(ictx)
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pancakes are made from batter, flipped, syruped and eaten.
+type Pancakes struct {
+	Count   int
+	Options []Option
+}
+
+func (Pancakes) Name() string {
+	return "Pancakes"
+}
+
+func (p Pancakes) Prepare(ctx context.Context) error {
+	//Lets make some pancakes
+	if err := checkCount(p.Count); err != nil {
+		return err
+	}
+	cakes, err := MakePancakesFromBatter(BatterFor(p.Count))
+	if err != nil {
+		return err
+	}
+
+	o := newOptions(p.Options)
+	if o.kitchen != nil {
+		_, err = o.kitchen.cook(ctx, cakes, p.Options)
+	} else {
+		err = FlipPancakes(ctx, cakes, p.Options...)
+	}
+	if err != nil {
+		return err
+	}
+	// Syruping stops if whoever is eating leaves early
+	sctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	// The breakfast is ruined if the syruping gives up, not just short of a
+	// few soggy pancakes
+	var syrupErr error
+	ready := syrupPancakes(sctx, cakes, contextOptions(sctx, p.Options), &syrupErr)
+	o.ate(eatPancakes(ctx, ready, o, func() { stop(ErrCustomerLeft) }))
+	return syrupErr
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+// fakeRecipe counts how many times it is prepared.
+type fakeRecipe struct {
+	name     string
+	prepared *int
+}
+
+func (r fakeRecipe) Name() string { return r.name }
+
+func (r fakeRecipe) Prepare(context.Context) error {
+	*r.prepared++
+	return nil
+}
+
+func TestBreakfastServesEveryItem(t *testing.T) {
+	mt := useMockTracer(t)
+
+	var toast, eggs int
+	meal := &Breakfast{Items: []Recipe{
+		fakeRecipe{name: "Toast", prepared: &toast},
+		fakeRecipe{name: "Eggs", prepared: &eggs},
+	}}
+	if err := meal.Serve(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if toast != 1 || eggs != 1 {
+		t.Errorf("prepared toast %d times and eggs %d times, want once each", toast, eggs)
+	}
+	breakfasttest.AssertSpanCount(t, mt, "Toast", 1)
+	breakfasttest.AssertSpanCount(t, mt, "Eggs", 1)
+}
+
+func TestPancakesStopWhenTheCustomerLeaves(t *testing.T) {
+	mt := useMockTracer(t)
+
+	recipe := Pancakes{Count: 5, Options: []Option{WithDryRun(), WithAppetite(2)}}
+	if err := recipe.Prepare(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := breakfasttest.SpanTag(mt, "EatPancakes", "pancakes.eaten"); got != 2 {
+		t.Errorf("EatPancakes pancakes.eaten = %v, want 2", got)
+	}
+	// Syruping stops once the customer leaves, and that isn't a failure
+	waitForEvent(t, mt, "PancakeReady")
+	if left, _ := eventField(mt, "PancakeReady", "customer_left"); left != "true" {
+		t.Errorf("PancakeReady customer_left = %q, want true", left)
+	}
+	if errField, ok := eventField(mt, "PancakeReady", "error"); ok {
+		t.Errorf("PancakeReady failed with %q, want no error", errField)
+	}
+	// The pancake being handed over as they left may still have gone out
+	if syruped, _ := eventField(mt, "PancakeReady", "syruped"); syruped != "2" && syruped != "3" {
+		t.Errorf("PancakeReady syruped = %q, want 2, or 3 at most", syruped)
+	}
+}
+
+func TestPancakesFailWhenSyrupingGivesUp(t *testing.T) {
+	mt := useMockTracer(t)
+	// soggyAt counts as it goes, so each breakfast needs its own
+	giveUp := func() Pancakes {
+		return Pancakes{Count: 5, Options: []Option{cleanFlips(), neverBurns(), WithCookDuration(time.Millisecond), soggyAt(0, 1, 2), WithMaxMistakes(2)}}
+	}
+
+	if err := giveUp().Prepare(context.Background()); !errors.Is(err, ErrTooManyMistakes) {
+		t.Fatalf("got %v, want %v", err, ErrTooManyMistakes)
+	}
+	if err := (&Breakfast{Items: []Recipe{giveUp()}}).Serve(context.Background()); !errors.Is(err, ErrTooManyMistakes) {
+		t.Fatalf("breakfast got %v, want %v", err, ErrTooManyMistakes)
+	}
+	if failed := breakfasttest.SpanTag(mt, "Pancakes", "error"); failed != true {
+		t.Errorf("Pancakes error = %v, want true", failed)
+	}
+}
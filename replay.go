@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+var ErrBadSpanRecord = errors.New("bad span record")
+
+// Replay reads spans recorded by the file reporter from r and reports them
+// again with the global tracer. They get new trace and span IDs, but keep
+// their original names, tags, start times and durations, and their parents
+// wherever the parent was recorded too. Nothing is replayed if any line
+// can't be read.
+func Replay(r io.Reader) error {
+	var recs []spanRecord
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec spanRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("line %d: %w: %s", line, ErrBadSpanRecord, err)
+		}
+		if rec.Operation == "" || rec.SpanID == "" {
+			return fmt.Errorf("line %d: %w: missing operation or span_id", line, ErrBadSpanRecord)
+		}
+		recs = append(recs, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// Spans are recorded as they finish, children before their parents, so
+	// start them in the order they originally started instead
+	sort.SliceStable(recs, func(i, j int) bool {
+		return recs[i].Start.Before(recs[j].Start)
+	})
+
+	tracer := opentracing.GlobalTracer()
+	replayed := make(map[string]opentracing.SpanContext, len(recs))
+	for _, rec := range recs {
+		opts := []opentracing.StartSpanOption{opentracing.StartTime(rec.Start)}
+		if parent, ok := replayed[rec.ParentID]; ok && rec.ParentID != "" {
+			opts = append(opts, opentracing.ChildOf(parent))
+		}
+		for k, v := range rec.Tags {
+			opts = append(opts, opentracing.Tag{Key: k, Value: v})
+		}
+		span := tracer.StartSpan(rec.Operation, opts...)
+		span.FinishWithOptions(opentracing.FinishOptions{
+			FinishTime: rec.Start.Add(time.Duration(rec.DurationMS * float64(time.Millisecond))),
+		})
+		replayed[rec.SpanID] = span.Context()
+	}
+	return nil
+}
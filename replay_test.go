@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+// recordSpans writes recs as the file reporter would.
+func recordSpans(t *testing.T, recs ...spanRecord) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, rec := range recs {
+		if err := writeJSON(&buf, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buf
+}
+
+func TestReplayRebuildsTrace(t *testing.T) {
+	mt := useMockTracer(t)
+
+	start := time.Unix(1700000000, 0).UTC()
+	// As recorded, children finish and are written before their parent
+	buf := recordSpans(t,
+		spanRecord{TraceID: "a1", SpanID: "2", ParentID: "1", Operation: "FlipPancakes", Start: start.Add(time.Millisecond), DurationMS: 1000},
+		spanRecord{TraceID: "a1", SpanID: "3", ParentID: "1", Operation: "EatPancakes", Start: start.Add(1001 * time.Millisecond), DurationMS: 500, Tags: map[string]interface{}{"pancakes.eaten": 3}},
+		spanRecord{TraceID: "a1", SpanID: "1", Operation: "ServeHotCakes", Start: start, DurationMS: 1501.5},
+	)
+	if err := Replay(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := mt.FinishedSpans(); len(spans) != 3 {
+		t.Fatalf("replayed %d spans, want 3", len(spans))
+	}
+	root := breakfasttest.AssertSpanExists(t, mt, "ServeHotCakes")
+	if root.ParentID != 0 {
+		t.Errorf("ServeHotCakes has parent %d, want none", root.ParentID)
+	}
+	if !root.StartTime.Equal(start) {
+		t.Errorf("ServeHotCakes started at %v, want %v", root.StartTime, start)
+	}
+	if took, want := root.FinishTime.Sub(root.StartTime), 1501500*time.Microsecond; took != want {
+		t.Errorf("ServeHotCakes took %s, want %s", took, want)
+	}
+	for _, name := range []string{"FlipPancakes", "EatPancakes"} {
+		span := breakfasttest.AssertSpanExists(t, mt, name)
+		if span.ParentID != root.SpanContext.SpanID {
+			t.Errorf("%s has parent %d, want ServeHotCakes' %d", name, span.ParentID, root.SpanContext.SpanID)
+		}
+		if span.SpanContext.TraceID != root.SpanContext.TraceID {
+			t.Errorf("%s is in trace %d, want ServeHotCakes' %d", name, span.SpanContext.TraceID, root.SpanContext.TraceID)
+		}
+	}
+	// JSON numbers come back as float64
+	if eaten := breakfasttest.SpanTag(mt, "EatPancakes", "pancakes.eaten"); eaten != float64(3) {
+		t.Errorf("EatPancakes pancakes.eaten = %v, want 3", eaten)
+	}
+}
+
+func TestReplayRejectsBadRecords(t *testing.T) {
+	good, _ := json.Marshal(spanRecord{SpanID: "1", Operation: "ServeHotCakes", Start: time.Now()})
+	tests := []struct {
+		name  string
+		input string
+		line  string
+	}{
+		{name: "malformed", input: string(good) + "\n{not json\n", line: "line 2"},
+		{name: "no operation", input: "\n" + string(good) + "\n" + `{"span_id": "2"}` + "\n", line: "line 3"},
+		{name: "no span ID", input: `{"operation": "FlipPancakes"}` + "\n", line: "line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := useMockTracer(t)
+			err := Replay(strings.NewReader(tt.input))
+			if !errors.Is(err, ErrBadSpanRecord) {
+				t.Fatalf("got %v, want %v", err, ErrBadSpanRecord)
+			}
+			if !strings.HasPrefix(err.Error(), tt.line+":") {
+				t.Errorf("got %q, want it to say %s", err, tt.line)
+			}
+			if spans := mt.FinishedSpans(); len(spans) != 0 {
+				t.Errorf("replayed %d spans from bad input, want none", len(spans))
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+// Where the file reporter writes spans unless BREAKFAST_REPORTER_FILE says
+// otherwise
+const defaultReporterFile = "spans.jsonl"
+
+// newReporter picks where finished spans go from BREAKFAST_REPORTER:
+//
+//	jaeger, the default, sends them to Jaeger as set up by reporterConfig
+//	stdout prints them, one readable line each
+//	file writes them as JSON lines to BREAKFAST_REPORTER_FILE, spans.jsonl by default
+func newReporter() (jaeger.Reporter, error) {
+	switch kind := os.Getenv("BREAKFAST_REPORTER"); kind {
+	case "", "jaeger":
+		// Report through a circuit breaker so a dead backend can't hold up
+		// breakfast, logging the spans too as the Jaeger config would
+		sender, err := newTransport(reporterConfig())
+		if err != nil {
+			return nil, err
+		}
+		breaker := NewBreakerTransport(sender, breakerThreshold, breakerCooldown)
+		reportBreaker.Store(breaker)
+		return jaeger.NewCompositeReporter(
+			jaeger.NewRemoteReporter(breaker),
+			jaeger.NewLoggingReporter(jaeger.StdLogger),
+		), nil
+	case "stdout":
+		return NewTextReporter(os.Stdout), nil
+	case "file":
+		path := os.Getenv("BREAKFAST_REPORTER_FILE")
+		if path == "" {
+			path = defaultReporterFile
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return NewJSONReporter(f), nil
+	default:
+		return nil, fmt.Errorf("unknown reporter %q, expected jaeger, stdout or file", kind)
+	}
+}
+
+// spanRecord is a finished span as the local reporters write it.
+type spanRecord struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	Operation  string                 `json:"operation"`
+	Start      time.Time              `json:"start"`
+	DurationMS float64                `json:"duration_ms"`
+	Tags       map[string]interface{} `json:"tags,omitempty"`
+}
+
+func newSpanRecord(span *jaeger.Span) spanRecord {
+	sc := span.SpanContext()
+	rec := spanRecord{
+		TraceID:    sc.TraceID().String(),
+		SpanID:     sc.SpanID().String(),
+		Operation:  span.OperationName(),
+		Start:      span.StartTime(),
+		DurationMS: float64(span.Duration()) / float64(time.Millisecond),
+		Tags:       span.Tags(),
+	}
+	if sc.ParentID() != 0 {
+		rec.ParentID = sc.ParentID().String()
+	}
+	return rec
+}
+
+// writerReporter writes each finished span to w with write, one at a time
+// since spans finish on many goroutines.
+type writerReporter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	write func(io.Writer, spanRecord) error
+}
+
+func (r *writerReporter) Report(span *jaeger.Span) {
+	rec := newSpanRecord(span)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.write(r.w, rec); err != nil {
+		log.Warningf("Couldn't report span %s: %s", rec.Operation, err)
+	}
+}
+
+// Close closes w if it is an io.Closer, other than stdout and stderr.
+func (r *writerReporter) Close() {
+	if r.w == os.Stdout || r.w == os.Stderr {
+		return
+	}
+	if c, ok := r.w.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// NewTextReporter prints finished spans to w for reading by eye, e.g.
+//
+//	FlipPancake 1.002s trace=1a2b span=3c4d parent=5e6f pancake.index=0 pancake.state=cooked
+func NewTextReporter(w io.Writer) jaeger.Reporter {
+	return &writerReporter{w: w, write: writeText}
+}
+
+func writeText(w io.Writer, rec spanRecord) error {
+	line := fmt.Sprintf("%s %s trace=%s span=%s", rec.Operation, time.Duration(rec.DurationMS*float64(time.Millisecond)), rec.TraceID, rec.SpanID)
+	if rec.ParentID != "" {
+		line += " parent=" + rec.ParentID
+	}
+	keys := make([]string, 0, len(rec.Tags))
+	for k := range rec.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		line += fmt.Sprintf(" %s=%v", k, rec.Tags[k])
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+// NewJSONReporter writes finished spans to w as JSON, one per line. w is
+// closed with the reporter if it is an io.Closer.
+func NewJSONReporter(w io.Writer) jaeger.Reporter {
+	return &writerReporter{w: w, write: writeJSON}
+}
+
+func writeJSON(w io.Writer, rec spanRecord) error {
+	return json.NewEncoder(w).Encode(rec)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	jaeger "github.com/uber/jaeger-client-go"
+)
+
+func TestJSONReporterWritesSpanRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer, closer := jaeger.NewTracer("breakfast-test", jaeger.NewConstSampler(true), NewJSONReporter(f))
+
+	root := tracer.StartSpan("ServeHotCakes")
+	flip := tracer.StartSpan("FlipPancake", opentracing.ChildOf(root.Context()))
+	flip.SetTag("pancake.index", 2)
+	flip.Finish()
+	root.Finish()
+	// Closing the tracer flushes the spans and closes the file
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var recs []spanRecord
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		var rec spanRecord
+		if err := json.Unmarshal(lines.Bytes(), &rec); err != nil {
+			t.Fatalf("line %d: %v", len(recs)+1, err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("got %d span records, want 2", len(recs))
+	}
+
+	// Spans are written as they finish, so the child comes first
+	got, parent := recs[0], recs[1]
+	if got.Operation != "FlipPancake" || parent.Operation != "ServeHotCakes" {
+		t.Fatalf("got operations %q and %q, want FlipPancake then ServeHotCakes", got.Operation, parent.Operation)
+	}
+	if got.TraceID != parent.TraceID {
+		t.Errorf("FlipPancake trace %s, want its parent's %s", got.TraceID, parent.TraceID)
+	}
+	if got.ParentID != parent.SpanID {
+		t.Errorf("FlipPancake parent %q, want %q", got.ParentID, parent.SpanID)
+	}
+	if parent.ParentID != "" {
+		t.Errorf("ServeHotCakes parent %q, want none", parent.ParentID)
+	}
+	if got.Start.IsZero() || got.DurationMS < 0 {
+		t.Errorf("FlipPancake start %v and duration %vms, want a real start", got.Start, got.DurationMS)
+	}
+	// JSON numbers come back as float64
+	if index := got.Tags["pancake.index"]; index != float64(2) {
+		t.Errorf("FlipPancake pancake.index = %v, want 2", index)
+	}
+}
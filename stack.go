@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	ext "github.com/opentracing/opentracing-go/ext"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+var ErrStackTooTall = errors.New("pancake stack is too tall")
+
+// PancakeStack is a pile of finished pancakes, the last one pushed on top.
+type PancakeStack struct {
+	// MaxHeight is the most pancakes the stack holds, 0 for no limit
+	MaxHeight int
+
+	cakes []breakfast.Pancake
+}
+
+// Push puts p on top of the stack.
+func (s *PancakeStack) Push(p breakfast.Pancake) error {
+	if s.MaxHeight > 0 && len(s.cakes) >= s.MaxHeight {
+		return ErrStackTooTall
+	}
+	s.cakes = append(s.cakes, p)
+	return nil
+}
+
+// Pop takes the top pancake off the stack, false if it was empty.
+func (s *PancakeStack) Pop() (breakfast.Pancake, bool) {
+	if len(s.cakes) == 0 {
+		return breakfast.Pancake{}, false
+	}
+	p := s.cakes[len(s.cakes)-1]
+	s.cakes = s.cakes[:len(s.cakes)-1]
+	return p, true
+}
+
+func (s *PancakeStack) Height() int {
+	return len(s.cakes)
+}
+
+// StackPancakes pushes every pancake sent on ready onto the stack. The channel
+// is always drained so the producer never blocks; if the stack can't take
+// them all ErrStackTooTall is returned once it is.
+func StackPancakes(ctx context.Context, ready <-chan breakfast.Pancake, s *PancakeStack) error {
+	span, _ := startSpan(ctx, "StackPancakes")
+	defer span.Finish()
+
+	var err error
+	for p := range ready {
+		if perr := s.Push(p); perr != nil {
+			err = perr
+		}
+	}
+	span.SetTag("stack.height", s.Height())
+	if err != nil {
+		ext.Error.Set(span, true)
+	}
+	return err
+}
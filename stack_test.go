@@ -0,0 +1,27 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+func TestPancakeStackMaxHeight(t *testing.T) {
+	s := &PancakeStack{MaxHeight: 3}
+	for p, cake := range breakfast.MakePancakes(3) {
+		if err := s.Push(cake); err != nil {
+			t.Fatalf("pushing pancake %d: %v", p, err)
+		}
+	}
+	if h := s.Height(); h != 3 {
+		t.Errorf("stack is %d pancakes high, want 3", h)
+	}
+
+	if err := s.Push(breakfast.Pancake{}); !errors.Is(err, ErrStackTooTall) {
+		t.Errorf("pushing past the top got %v, want %v", err, ErrStackTooTall)
+	}
+	if h := s.Height(); h != 3 {
+		t.Errorf("stack is %d pancakes high after overflowing, want 3", h)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// The breaker of the Jaeger reporter made last, if any
+var reportBreaker atomic.Pointer[BreakerTransport]
+
+// tracerFailing reports whether the Jaeger reporter's breaker has given up
+// on the backend.
+func tracerFailing() bool {
+	b := reportBreaker.Load()
+	return b != nil && b.State() == BreakerOpen
+}
+
+// TracerSupervisor is a tracer that replaces the one it traces with when that
+// one keeps failing to report. It is safe to set as the global tracer once and
+// leave there, as the swap happens underneath it: spans already started stay
+// with the old tracer and new ones go to the new one. The old tracer is only
+// closed at the next swap, or when the supervisor is closed, so the spans
+// still open on it have time to finish.
+type TracerSupervisor struct {
+	init    func() (opentracing.Tracer, io.Closer, error)
+	failing func() bool
+
+	tracer atomic.Pointer[opentracing.Tracer]
+	mu     sync.Mutex
+	closer io.Closer
+	// The closer of the tracer replaced last, kept open for its spans
+	retired io.Closer
+}
+
+// NewTracerSupervisor returns a supervisor that makes its tracers with init
+// and replaces them whenever failing reports true.
+func NewTracerSupervisor(init func() (opentracing.Tracer, io.Closer, error), failing func() bool) *TracerSupervisor {
+	return &TracerSupervisor{init: init, failing: failing}
+}
+
+// Start makes the first tracer. It must be called before the supervisor is
+// used to trace.
+func (s *TracerSupervisor) Start() error {
+	return s.reinit()
+}
+
+// Run checks every interval whether the tracer is failing, replacing it if
+// so, until ctx is cancelled. A tracer that can't be replaced is kept, and
+// replacing it is tried again next time.
+func (s *TracerSupervisor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !s.failing() {
+				continue
+			}
+			log.Warning("Tracing backend keeps failing, reinitializing the tracer")
+			if err := s.reinit(); err != nil {
+				log.Warningf("Couldn't reinitialize the tracer: %s", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *TracerSupervisor) reinit() error {
+	tracer, closer, err := s.init()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracer.Store(&tracer)
+	if s.retired != nil {
+		// Its spans have had a whole interval to finish, so flush what it
+		// still has, in case it gets through
+		s.retired.Close()
+	}
+	s.retired, s.closer = s.closer, closer
+	return nil
+}
+
+// Close closes the current tracer, and the one it replaced if that is still
+// open, flushing their buffered spans.
+func (s *TracerSupervisor) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for _, c := range []io.Closer{s.retired, s.closer} {
+		if c == nil {
+			continue
+		}
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	s.retired, s.closer = nil, nil
+	return err
+}
+
+func (s *TracerSupervisor) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	return (*s.tracer.Load()).StartSpan(operationName, opts...)
+}
+
+func (s *TracerSupervisor) Inject(sm opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	return (*s.tracer.Load()).Inject(sm, format, carrier)
+}
+
+func (s *TracerSupervisor) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	return (*s.tracer.Load()).Extract(format, carrier)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	mocktracer "github.com/opentracing/opentracing-go/mocktracer"
+)
+
+// countingCloser counts how often it is closed.
+type countingCloser struct{ closed int }
+
+func (c *countingCloser) Close() error {
+	c.closed++
+	return nil
+}
+
+// supervisedTracer is a tracer the supervisor made, with its closer.
+type supervisedTracer struct {
+	tracer *mocktracer.MockTracer
+	closer *countingCloser
+}
+
+// flakyInit makes a fresh mock tracer each call, except while down is set,
+// when it fails. made holds every tracer it made, in order.
+func flakyInit(down *bool, made *[]supervisedTracer) func() (opentracing.Tracer, io.Closer, error) {
+	return func() (opentracing.Tracer, io.Closer, error) {
+		if *down {
+			return nil, nil, errors.New("no tracing backend")
+		}
+		st := supervisedTracer{tracer: mocktracer.New(), closer: &countingCloser{}}
+		*made = append(*made, st)
+		return st.tracer, st.closer, nil
+	}
+}
+
+func TestTracerSupervisorKeepsOldTracerOpen(t *testing.T) {
+	var down bool
+	var made []supervisedTracer
+	s := NewTracerSupervisor(flakyInit(&down, &made), func() bool { return true })
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A span in flight on the first tracer when the backend goes away
+	inflight := s.StartSpan("ServeHotCakes")
+	down = true
+	if err := s.reinit(); err == nil {
+		t.Fatal("replaced the tracer while the factory was failing")
+	}
+	if len(made) != 1 || made[0].closer.closed != 0 {
+		t.Fatal("a failed replacement touched the working tracer")
+	}
+
+	// The factory recovers, and new spans go to the new tracer while the
+	// old one stays open for the span in flight
+	down = false
+	if err := s.reinit(); err != nil {
+		t.Fatal(err)
+	}
+	if made[0].closer.closed != 0 {
+		t.Error("closed the old tracer with a span still in flight")
+	}
+	s.StartSpan("ServeHotCakes").Finish()
+	inflight.Finish()
+	if n := len(made[0].tracer.FinishedSpans()); n != 1 {
+		t.Errorf("old tracer finished %d spans, want the one in flight", n)
+	}
+	if n := len(made[1].tracer.FinishedSpans()); n != 1 {
+		t.Errorf("new tracer finished %d spans, want 1", n)
+	}
+
+	// The next swap retires the new tracer and closes the old one
+	if err := s.reinit(); err != nil {
+		t.Fatal(err)
+	}
+	if made[0].closer.closed != 1 || made[1].closer.closed != 0 {
+		t.Errorf("closed tracers %d and %d times, want the oldest once", made[0].closer.closed, made[1].closer.closed)
+	}
+
+	// Closing the supervisor closes what is left, once each
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, st := range made {
+		if st.closer.closed != 1 {
+			t.Errorf("tracer %d closed %d times, want once", i, st.closer.closed)
+		}
+	}
+}
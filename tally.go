@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// tally counts what happened to the pancakes of a single breakfast. Stages
+// may run on several goroutines, so the counts are atomic.
+type tally struct {
+	cooked  atomic.Int64
+	burnt   atomic.Int64
+	soggy   atomic.Int64
+	syruped atomic.Int64
+	eaten   atomic.Int64
+	// Pancakes really syruped, which a dry run never does
+	poured atomic.Int64
+}
+
+// BreakfastSummary is what happened to a breakfast's pancakes.
+type BreakfastSummary struct {
+	Cooked int
+	Burnt  int
+	// Pancakes still soggy once syruping was done. One a retry fixed counts
+	// as served, not soggy.
+	Soggy    int
+	Served   int
+	Duration time.Duration
+	// The breakfast's trace, when the tracer is Jaeger's
+	TraceID string
+}
+
+func (t *tally) summary(took time.Duration) BreakfastSummary {
+	return BreakfastSummary{
+		Cooked:   int(t.cooked.Load()),
+		Burnt:    int(t.burnt.Load()),
+		Soggy:    int(t.soggy.Load()),
+		Served:   int(t.eaten.Load()),
+		Duration: took,
+	}
+}
+
+// calories is what the pancakes eaten came to. Only the ones really syruped
+// count the syrup.
+func (t *tally) calories() int64 {
+	eaten := t.eaten.Load()
+	return eaten*DryPancakeCalories + min(eaten, t.poured.Load())*SyrupCalories
+}
+
+func withTally(t *tally) Option {
+	return func(o *options) {
+		o.tally = t
+	}
+}
+
+// The helpers below count an outcome in both the breakfast's tally and the
+// Metrics, whichever of them are set.
+
+func (o *options) cooked() {
+	o.metrics.cooked()
+	if o.tally != nil {
+		o.tally.cooked.Add(1)
+	}
+}
+
+func (o *options) burnt() {
+	o.metrics.burnt()
+	if o.tally != nil {
+		o.tally.burnt.Add(1)
+	}
+}
+
+// soggy counts every time a pancake goes soggy in the Metrics, but leaves
+// the tally to leftSoggy, which only counts the pancakes never fixed.
+func (o *options) soggy() {
+	o.metrics.soggy()
+}
+
+func (o *options) leftSoggy(n int) {
+	if o.tally != nil {
+		o.tally.soggy.Add(int64(n))
+	}
+}
+
+func (o *options) syruped() {
+	if o.tally != nil {
+		o.tally.syruped.Add(1)
+	}
+}
+
+func (o *options) pouredSyrup() {
+	if o.tally != nil {
+		o.tally.poured.Add(1)
+	}
+}
+
+func (o *options) ate(n int) {
+	if o.tally != nil {
+		o.tally.eaten.Add(int64(n))
+	}
+}
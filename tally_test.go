@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	testutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSummaryCountsKnownOutcomes(t *testing.T) {
+	useMockTracer(t)
+	m := registeredMetrics(t)
+
+	// Pancakes 0, 1 and 3 go soggy, and retrying fixes all but pancake 1
+	summary, err := ServeBreakfastWithSummary(context.Background(),
+		WithPancakeCount(4), WithCoffee(0), WithMetrics(m),
+		cleanFlips(), neverBurns(), WithCookDuration(time.Millisecond),
+		soggyAt(0, 1, 3, 5), WithSyrupRetries(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BreakfastSummary{Cooked: 4, Burnt: 0, Soggy: 1, Served: 3}
+	if summary.Cooked != want.Cooked || summary.Burnt != want.Burnt || summary.Soggy != want.Soggy || summary.Served != want.Served {
+		t.Errorf("got %+v, want %+v", summary, want)
+	}
+	// The metrics count every time a pancake went soggy
+	if soggy := testutil.ToFloat64(m.Soggy); soggy != 4 {
+		t.Errorf("counted %v soggy pancakes, want 4", soggy)
+	}
+}
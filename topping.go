@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	logging "github.com/ipfs/go-log"
+	ext "github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+
+	breakfast "github.com/frrist/breakfast"
+)
+
+// Topping is something put on a finished pancake.
+type Topping interface {
+	// Name of the topping, tagged on its span
+	Name() string
+	Apply(p *breakfast.Pancake) error
+}
+
+type toppingFunc struct {
+	name  string
+	apply func(*breakfast.Pancake) error
+}
+
+func (t toppingFunc) Name() string                     { return t.name }
+func (t toppingFunc) Apply(p *breakfast.Pancake) error { return t.apply(p) }
+
+// NewTopping makes a Topping called name out of apply.
+func NewTopping(name string, apply func(*breakfast.Pancake) error) Topping {
+	return toppingFunc{name: name, apply: apply}
+}
+
+// Syrup pours syrup on the pancake, which may leave it soggy.
+var Syrup = NewTopping("syrup", func(p *breakfast.Pancake) error {
+	if err := p.Syrup(); err != nil {
+		return ErrSoggyPancake
+	}
+	return nil
+})
+
+// AddToppings passes each pancake from in through every topping in turn,
+// sending the ones that took them all on the returned channel. A pancake a
+// topping fails on goes with the mistakes instead, which WithMistakes stores.
+// Each topping applied gets its own span. opts apply as they do to
+// SyrupPancakes, so toppings are held to a WithMaxConcurrency limit and
+// always take in a dry run.
+func AddToppings(ctx context.Context, in <-chan breakfast.Pancake, toppings []Topping, opts ...Option) <-chan breakfast.Pancake {
+	o := contextOptions(ctx, opts)
+	eip := log.EventBegin(ctx, "AddToppings", eventFields(ctx, logging.LoggableMap{"toppings": len(toppings)}))
+	out := make(chan breakfast.Pancake, o.buffer)
+	go func() {
+		// Where pancakes with a failed topping go..
+		var mistakes []breakfast.Pancake
+		topped := 0
+		defer func() {
+			if o.mistakes != nil {
+				*o.mistakes = mistakes
+			}
+			close(out)
+			eip.Append(logging.LoggableMap{"topped": topped, "mistakes": len(mistakes)})
+			eip.Done()
+		}()
+
+		for cake := range in {
+			if err := addToppings(ctx, &cake, toppings, o); err != nil {
+				log.Warning(err)
+				mistakes = append(mistakes, cake)
+				continue
+			}
+			select {
+			case out <- cake:
+				topped++
+			case <-ctx.Done():
+				eip.SetError(ctx.Err())
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func addToppings(ctx context.Context, cake *breakfast.Pancake, toppings []Topping, o *options) error {
+	for _, t := range toppings {
+		span, _ := startSpan(ctx, "AddTopping")
+		span.SetTag("topping", t.Name())
+		err := o.apply(t, cake)
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(otlog.Error(err))
+		}
+		span.Finish()
+		if err != nil {
+			return fmt.Errorf("adding %s: %w", t.Name(), err)
+		}
+	}
+	return nil
+}
+
+// apply stands in for the topping's own Apply like flip does for Flip, so a
+// dry run takes every topping.
+func (o *options) apply(t Topping, cake *breakfast.Pancake) error {
+	if o.dryRun {
+		return nil
+	}
+	return o.limited(func() error { return t.Apply(cake) })
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	breakfast "github.com/frrist/breakfast"
+
+	"github.com/frrist/TracesOfBreakfast/breakfasttest"
+)
+
+var errNoBerries = errors.New("out of berries")
+
+// pancakesOn sends the pancakes on a closed channel, as if they were ready.
+func pancakesOn(cakes []breakfast.Pancake) <-chan breakfast.Pancake {
+	ready := make(chan breakfast.Pancake, len(cakes))
+	for _, cake := range cakes {
+		ready <- cake
+	}
+	close(ready)
+	return ready
+}
+
+// berriesRunOut is a topping that fails on the nth pancake it is applied to,
+// counting from 0.
+func berriesRunOut(n int) Topping {
+	applied := 0
+	return NewTopping("berries", func(*breakfast.Pancake) error {
+		applied++
+		if applied-1 == n {
+			return errNoBerries
+		}
+		return nil
+	})
+}
+
+func TestAddToppingsLeavesOutFailedPancakes(t *testing.T) {
+	mt := useMockTracer(t)
+	butter := NewTopping("butter", func(*breakfast.Pancake) error { return nil })
+
+	var mistakes []breakfast.Pancake
+	got := collect(AddToppings(context.Background(), pancakesOn(breakfast.MakePancakes(3)), []Topping{butter, berriesRunOut(1)}, WithMistakes(&mistakes)))
+	if len(got) != 2 {
+		t.Errorf("got %d pancakes, want the 2 that took every topping", len(got))
+	}
+	if len(mistakes) != 1 {
+		t.Errorf("got %d mistakes, want the pancake that ran out of berries", len(mistakes))
+	}
+	// Each topping was tried on every pancake
+	breakfasttest.AssertSpanCount(t, mt, "AddTopping", 6)
+	waitForEvent(t, mt, "AddToppings")
+	if topped, _ := eventField(mt, "AddToppings", "topped"); topped != "2" {
+		t.Errorf("AddToppings topped = %q, want 2", topped)
+	}
+}
+
+func TestAddToppingsDryRun(t *testing.T) {
+	useMockTracer(t)
+
+	got := collect(AddToppings(context.Background(), pancakesOn(breakfast.MakePancakes(3)), []Topping{berriesRunOut(0)}, WithDryRun()))
+	if len(got) != 3 {
+		t.Errorf("got %d pancakes, want every one topped in a dry run", len(got))
+	}
+}
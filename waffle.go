@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrWaffleAlreadyFlipped = errors.New("waffle iron already flipped")
+
+// A waffle with this many squares cooks as fast as a pancake
+const standardSquares = 16
+
+// Waffle is cooked in a flip-style waffle iron, which is turned over once.
+type Waffle struct {
+	// Squares is how many squares the iron presses; more squares take longer
+	Squares int
+
+	flipped bool
+}
+
+func (w *Waffle) Flip() error {
+	if w.flipped {
+		return ErrWaffleAlreadyFlipped
+	}
+	w.flipped = true
+	return nil
+}
+
+// IsBurnt is always false: the iron keeps waffles from burning.
+func (w *Waffle) IsBurnt() bool {
+	return false
+}
+
+// CookTime scales the pancake cook time by how many squares the waffle has.
+func (w *Waffle) CookTime(base time.Duration) time.Duration {
+	if w.Squares <= standardSquares {
+		return base
+	}
+	return base * time.Duration(w.Squares) / standardSquares
+}